@@ -0,0 +1,162 @@
+package gohttplb
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// AdaptiveAlgorithm is algorithm adjusting adaptive concurrency limit
+type AdaptiveAlgorithm int
+
+const (
+	// AdaptiveAIMD increase limit by one on success while limit is used,
+	// multiply it by BackoffRatio on failure or latency over LatencyThreshold
+	AdaptiveAIMD AdaptiveAlgorithm = iota
+	// AdaptiveGradient scale limit by ratio of long term latency to latest latency,
+	// so limit shrinks when latency rises above its baseline, failures back off like AdaptiveAIMD
+	AdaptiveGradient
+)
+
+func (a AdaptiveAlgorithm) String() string {
+	switch a {
+	case AdaptiveAIMD:
+		return "aimd"
+	case AdaptiveGradient:
+		return "gradient"
+	}
+	return "unknown"
+}
+
+// Default adaptive limit config
+var (
+	DefaultAdaptiveInitialLimit = 20
+	DefaultAdaptiveMinLimit     = 1
+	DefaultAdaptiveMaxLimit     = 200
+	DefaultAdaptiveBackoffRatio = 0.9
+	DefaultAdaptiveTolerance    = 1.5
+	DefaultAdaptiveSmoothing    = 0.2
+)
+
+// adaptiveLongRTTAlpha weight of latest latency in long term latency of AdaptiveGradient
+const adaptiveLongRTTAlpha = 0.01
+
+// AdaptiveLimitConfig for per server concurrency limit adjusted by observed latency and failures
+type AdaptiveLimitConfig struct {
+	// Algorithm AdaptiveAIMD or AdaptiveGradient
+	// Default AdaptiveAIMD
+	Algorithm AdaptiveAlgorithm
+	// InitialLimit limit before any response
+	// Default MaxConcurrentPerServer of BulkheadConfig, or 20 if not set
+	InitialLimit int
+	// MinLimit
+	// Default 1
+	MinLimit int
+	// MaxLimit
+	// Default 200
+	MaxLimit int
+	// BackoffRatio multiply limit by it on failure, range (0, 1)
+	// Default 0.9
+	BackoffRatio float64
+	// LatencyThreshold AdaptiveAIMD treats slower responses as failures
+	// Disabled if 0
+	LatencyThreshold time.Duration
+	// Tolerance AdaptiveGradient latency over long term latency tolerated before limit shrinks
+	// Default 1.5
+	Tolerance float64
+	// Smoothing AdaptiveGradient weight of new limit, range (0, 1]
+	// Default 0.2
+	Smoothing float64
+}
+
+func setDefaultAdaptiveLimitConf(conf *AdaptiveLimitConfig, maxConcurrentPerServer int) {
+	if conf.InitialLimit == 0 {
+		conf.InitialLimit = DefaultAdaptiveInitialLimit
+		if maxConcurrentPerServer > 0 {
+			conf.InitialLimit = maxConcurrentPerServer
+		}
+	}
+	if conf.MinLimit == 0 {
+		conf.MinLimit = DefaultAdaptiveMinLimit
+	}
+	if conf.MaxLimit == 0 {
+		conf.MaxLimit = DefaultAdaptiveMaxLimit
+	}
+	if conf.BackoffRatio == 0 {
+		conf.BackoffRatio = DefaultAdaptiveBackoffRatio
+	}
+	if conf.Tolerance == 0 {
+		conf.Tolerance = DefaultAdaptiveTolerance
+	}
+	if conf.Smoothing == 0 {
+		conf.Smoothing = DefaultAdaptiveSmoothing
+	}
+}
+
+// adaptiveLimiter is concurrency limit of a server
+type adaptiveLimiter struct {
+	conf *AdaptiveLimitConfig
+
+	mutex sync.Mutex
+	limit float64
+	// longRTT long term latency EWMA of AdaptiveGradient
+	longRTT float64
+}
+
+func newAdaptiveLimiter(conf *AdaptiveLimitConfig) *adaptiveLimiter {
+	return &adaptiveLimiter{
+		conf:  conf,
+		limit: float64(conf.InitialLimit),
+	}
+}
+
+// getLimit return current limit
+func (l *adaptiveLimiter) getLimit() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return int(l.limit)
+}
+
+// sample adjust limit by result of a request, inFlight requests including it
+func (l *adaptiveLimiter) sample(latency time.Duration, inFlight int64, failure bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if failure {
+		l.setLimit(l.limit * l.conf.BackoffRatio)
+		return
+	}
+
+	switch l.conf.Algorithm {
+	case AdaptiveGradient:
+		rtt := float64(latency)
+		if rtt <= 0 {
+			return
+		}
+		if l.longRTT == 0 {
+			l.longRTT = rtt
+		} else {
+			l.longRTT = adaptiveLongRTTAlpha*rtt + (1-adaptiveLongRTTAlpha)*l.longRTT
+		}
+		gradient := math.Max(0.5, math.Min(1, l.conf.Tolerance*l.longRTT/rtt))
+		newLimit := l.limit * gradient
+		// queue allowance lets used limit grow while latency is at its baseline
+		if float64(inFlight)*2 >= l.limit {
+			newLimit += math.Sqrt(l.limit)
+		}
+		l.setLimit((1-l.conf.Smoothing)*l.limit + l.conf.Smoothing*newLimit)
+	default:
+		if l.conf.LatencyThreshold > 0 && latency > l.conf.LatencyThreshold {
+			l.setLimit(l.limit * l.conf.BackoffRatio)
+			return
+		}
+		// grow only if limit is used, idle servers keep their limit
+		if float64(inFlight)*2 >= l.limit {
+			l.setLimit(l.limit + 1)
+		}
+	}
+}
+
+// setLimit must hold mutex
+func (l *adaptiveLimiter) setLimit(limit float64) {
+	l.limit = math.Max(float64(l.conf.MinLimit), math.Min(float64(l.conf.MaxLimit), limit))
+}
@@ -0,0 +1,70 @@
+package gohttplb
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveLimiterSample(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name     string
+		conf     AdaptiveLimitConfig
+		limit    float64
+		longRTT  time.Duration
+		latency  time.Duration
+		inFlight int64
+		failure  bool
+		want     float64
+	}{
+		{"aimd grows when used", AdaptiveLimitConfig{}, 10, 0, 10 * ms, 5, false, 11},
+		{"aimd keeps idle limit", AdaptiveLimitConfig{}, 10, 0, 10 * ms, 4, false, 10},
+		{"aimd backs off on failure", AdaptiveLimitConfig{}, 10, 0, 10 * ms, 5, true, 9},
+		{"aimd backs off over latency threshold", AdaptiveLimitConfig{LatencyThreshold: 100 * ms},
+			10, 0, 200 * ms, 5, false, 9},
+		{"aimd grows under latency threshold", AdaptiveLimitConfig{LatencyThreshold: 100 * ms},
+			10, 0, 50 * ms, 5, false, 11},
+		{"aimd clamps to max", AdaptiveLimitConfig{MaxLimit: 10}, 10, 0, 10 * ms, 10, false, 10},
+		{"aimd clamps to min", AdaptiveLimitConfig{MinLimit: 2}, 2, 0, 10 * ms, 1, true, 2},
+		{"gradient keeps limit at baseline latency", AdaptiveLimitConfig{Algorithm: AdaptiveGradient},
+			10, 100 * ms, 100 * ms, 0, false, 10},
+		{"gradient grows when used", AdaptiveLimitConfig{Algorithm: AdaptiveGradient},
+			16, 100 * ms, 100 * ms, 8, false, 16.8},
+		{"gradient shrinks when latency rises", AdaptiveLimitConfig{Algorithm: AdaptiveGradient},
+			10, 100 * ms, 300 * ms, 0, false, 9.02},
+		{"gradient shrinks at most by half", AdaptiveLimitConfig{Algorithm: AdaptiveGradient},
+			10, 100 * ms, time.Second, 0, false, 9},
+		{"gradient backs off on failure", AdaptiveLimitConfig{Algorithm: AdaptiveGradient},
+			10, 100 * ms, 100 * ms, 0, true, 9},
+		{"gradient clamps to min", AdaptiveLimitConfig{Algorithm: AdaptiveGradient, MinLimit: 10},
+			10, 100 * ms, time.Second, 0, false, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := tt.conf
+			setDefaultAdaptiveLimitConf(&conf, 0)
+			l := newAdaptiveLimiter(&conf)
+			l.limit = tt.limit
+			l.longRTT = float64(tt.longRTT)
+
+			l.sample(tt.latency, tt.inFlight, tt.failure)
+			if math.Abs(l.limit-tt.want) > 1e-9 {
+				t.Fatalf("limit = %v, want %v", l.limit, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdaptiveLimitConfigDefaults(t *testing.T) {
+	conf := &AdaptiveLimitConfig{}
+	setDefaultAdaptiveLimitConf(conf, 8)
+	if conf.InitialLimit != 8 {
+		t.Fatalf("InitialLimit = %d, want MaxConcurrentPerServer 8", conf.InitialLimit)
+	}
+	conf = &AdaptiveLimitConfig{}
+	setDefaultAdaptiveLimitConf(conf, 0)
+	if conf.InitialLimit != DefaultAdaptiveInitialLimit {
+		t.Fatalf("InitialLimit = %d, want %d", conf.InitialLimit, DefaultAdaptiveInitialLimit)
+	}
+}
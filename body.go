@@ -0,0 +1,161 @@
+package gohttplb
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+// errBodyClosed is returned by reads of body closed after request finished
+var errBodyClosed = errors.New("request body closed")
+
+// requestBody is request body, replayable body is reopened for every attempt,
+// non replayable body can be retried only if it has not been read
+type requestBody struct {
+	// getBody return a new reader of replayable body
+	getBody func() (io.ReadCloser, error)
+	// reader of non replayable body
+	reader *onceReader
+	// data of in memory body
+	data []byte
+	// length body length, -1 if unknown
+	length int64
+}
+
+func bytesBody(body []byte) *requestBody {
+	if body == nil {
+		return nil
+	}
+	return &requestBody{
+		getBody: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(body)), nil
+		},
+		length: int64(len(body)),
+		data:   body,
+	}
+}
+
+// readerBody make body replayable for in memory readers like net/http does
+func readerBody(reader io.Reader) *requestBody {
+	switch v := reader.(type) {
+	case *bytes.Buffer:
+		return bytesBody(v.Bytes())
+	case *bytes.Reader:
+		snapshot := *v
+		return &requestBody{
+			getBody: func() (io.ReadCloser, error) {
+				r := snapshot
+				return ioutil.NopCloser(&r), nil
+			},
+			length: int64(v.Len()),
+		}
+	case *strings.Reader:
+		snapshot := *v
+		return &requestBody{
+			getBody: func() (io.ReadCloser, error) {
+				r := snapshot
+				return ioutil.NopCloser(&r), nil
+			},
+			length: int64(v.Len()),
+		}
+	}
+	return &requestBody{
+		reader: &onceReader{reader: reader},
+		length: -1,
+	}
+}
+
+func funcBody(getBody func() (io.ReadCloser, error), length int64) *requestBody {
+	return &requestBody{
+		getBody: getBody,
+		length:  length,
+	}
+}
+
+// replayable reports whether body can be sent more than once
+func (b *requestBody) replayable() bool {
+	return b == nil || b.getBody != nil
+}
+
+// retryable reports whether body can be sent again
+func (b *requestBody) retryable() bool {
+	return b.replayable() || !b.reader.consumed()
+}
+
+// close close non replayable body if no attempt read it, the transport closes it otherwise
+func (b *requestBody) close() {
+	if b != nil && b.reader != nil {
+		b.reader.closeUnread()
+	}
+}
+
+// open return body reader for an attempt
+func (b *requestBody) open() (io.ReadCloser, error) {
+	if b.getBody != nil {
+		return b.getBody()
+	}
+	if b.reader.consumed() {
+		return nil, ErrBodyNotReplayable
+	}
+	return b.reader, nil
+}
+
+// onceReader record whether reader has been read, Close is ignored until read so that
+// the reader is kept for retry if transport failed before reading it
+type onceReader struct {
+	reader io.Reader
+	mutex  sync.Mutex
+	read   bool
+	closed bool
+}
+
+func (r *onceReader) Read(p []byte) (int, error) {
+	r.mutex.Lock()
+	if r.closed {
+		r.mutex.Unlock()
+		return 0, errBodyClosed
+	}
+	r.read = true
+	r.mutex.Unlock()
+	return r.reader.Read(p)
+}
+
+func (r *onceReader) Close() error {
+	r.mutex.Lock()
+	if !r.read {
+		r.mutex.Unlock()
+		return nil
+	}
+	return r.close()
+}
+
+// closeUnread close reader never read by transport, called when request finished
+func (r *onceReader) closeUnread() error {
+	r.mutex.Lock()
+	if r.read {
+		r.mutex.Unlock()
+		return nil
+	}
+	return r.close()
+}
+
+// close close reader once, called with mutex locked
+func (r *onceReader) close() error {
+	closed := r.closed
+	r.closed = true
+	r.mutex.Unlock()
+	closer, ok := r.reader.(io.Closer)
+	if closed || !ok {
+		return nil
+	}
+	return closer.Close()
+}
+
+func (r *onceReader) consumed() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.read
+}
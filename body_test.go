@@ -0,0 +1,136 @@
+package gohttplb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestReaderBodyReplaysSnapshot(t *testing.T) {
+	partly := strings.NewReader("skip body")
+	partly.Read(make([]byte, 5))
+
+	tests := []struct {
+		name   string
+		reader io.Reader
+		want   string
+	}{
+		{"buffer", bytes.NewBufferString("body"), "body"},
+		{"bytes reader", bytes.NewReader([]byte("body")), "body"},
+		{"strings reader", strings.NewReader("body"), "body"},
+		{"partly read strings reader", partly, "body"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := readerBody(tt.reader)
+			if !body.replayable() {
+				t.Fatal("body not replayable")
+			}
+			if body.length != int64(len(tt.want)) {
+				t.Fatalf("length = %d, want %d", body.length, len(tt.want))
+			}
+			for i := 0; i < 2; i++ {
+				reader, err := body.open()
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, err := ioutil.ReadAll(reader)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(data) != tt.want {
+					t.Fatalf("attempt %d: body = %q, want %q", i, data, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBodyNotReplayableAfterRead(t *testing.T) {
+	var requests int32
+	_, addr := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		req.Body.Read(make([]byte, 1))
+		// drop connection after the body is partly read
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conn.Close()
+	})
+	lbc, err := New(addr, &LBConfig{Retry: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = lbc.NewRequest().
+		Method(http.MethodPost).
+		BodyReader(iotest.OneByteReader(strings.NewReader("body"))).
+		Do(context.Background())
+	if !errors.Is(err, ErrBodyNotReplayable) {
+		t.Fatalf("err = %v, want ErrBodyNotReplayable", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Fatalf("requests = %d, want 1", n)
+	}
+}
+
+// closeCounter count Close of a streaming body
+type closeCounter struct {
+	io.Reader
+	closes int32
+}
+
+func (c *closeCounter) Close() error {
+	atomic.AddInt32(&c.closes, 1)
+	return nil
+}
+
+func TestUnreadBodyClosed(t *testing.T) {
+	lbc, err := New(closedAddr(t), &LBConfig{Retry: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := &closeCounter{Reader: iotest.OneByteReader(strings.NewReader("body"))}
+	_, err = lbc.NewRequest().Method(http.MethodPost).BodyReader(body).Do(context.Background())
+	if err == nil {
+		t.Fatal("request to closed server succeeded")
+	}
+	if n := atomic.LoadInt32(&body.closes); n != 1 {
+		t.Fatalf("closes = %d, want 1", n)
+	}
+}
+
+func TestReadBodyClosedOnce(t *testing.T) {
+	_, addr := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		ioutil.ReadAll(req.Body)
+	})
+	lbc, err := New(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := &closeCounter{Reader: iotest.OneByteReader(strings.NewReader("body"))}
+	resp, err := lbc.NewRequest().Method(http.MethodPost).BodyReader(body).Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	// transport closes the body once it is written
+	for deadline := time.Now().Add(time.Second); atomic.LoadInt32(&body.closes) == 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&body.closes); n != 1 {
+		t.Fatalf("closes = %d, want 1", n)
+	}
+}
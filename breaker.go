@@ -0,0 +1,312 @@
+package gohttplb
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// BreakerState is circuit breaker state
+type BreakerState int
+
+const (
+	// BreakerClosed requests pass through and results are recorded
+	BreakerClosed BreakerState = iota
+	// BreakerOpen requests are rejected until OpenTimeout elapsed
+	BreakerOpen
+	// BreakerHalfOpen limited probe requests are allowed to test recovery
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Default breaker config
+var (
+	DefaultBreakerConsecutiveFailures = 5
+	DefaultBreakerMinRequests         = 10
+	DefaultBreakerWindow              = 10 * time.Second
+	DefaultBreakerOpenTimeout         = 30 * time.Second
+	DefaultBreakerHalfOpenRequests    = 1
+)
+
+// breakerWindowBuckets number of buckets in breaker rolling window
+const breakerWindowBuckets = 10
+
+// BreakerConfig for circuit breaker
+type BreakerConfig struct {
+	// ConsecutiveFailures trip breaker after this many consecutive failures
+	// Default DefaultBreakerConsecutiveFailures if FailureRatio not set either
+	ConsecutiveFailures int
+	// FailureRatio trip breaker when failure ratio in Window reaches it, range (0, 1]
+	// Disabled if 0
+	FailureRatio float64
+	// MinRequests minimum requests in Window before FailureRatio is checked
+	// Default 10
+	MinRequests int
+	// Window rolling window for FailureRatio
+	// Default 10s
+	Window time.Duration
+	// OpenTimeout how long breaker stays open before turning half-open
+	// Default 30s
+	OpenTimeout time.Duration
+	// HalfOpenRequests probe requests allowed in half-open state,
+	// breaker closes after all of them succeed
+	// Default 1
+	HalfOpenRequests int
+	// IsFailure decide whether a request result is failure
+	// Default: err != nil or status code >= 500
+	IsFailure func(resp *http.Response, err error) bool
+	// OnStateChange called after breaker state changed, server is empty for cluster breaker
+	OnStateChange func(server string, from, to BreakerState)
+}
+
+func setDefaultBreakerConf(conf *BreakerConfig) {
+	if conf.ConsecutiveFailures == 0 && conf.FailureRatio == 0 {
+		conf.ConsecutiveFailures = DefaultBreakerConsecutiveFailures
+	}
+	if conf.MinRequests == 0 {
+		conf.MinRequests = DefaultBreakerMinRequests
+	}
+	if conf.Window == 0 {
+		conf.Window = DefaultBreakerWindow
+	}
+	if conf.OpenTimeout == 0 {
+		conf.OpenTimeout = DefaultBreakerOpenTimeout
+	}
+	if conf.HalfOpenRequests == 0 {
+		conf.HalfOpenRequests = DefaultBreakerHalfOpenRequests
+	}
+	if conf.IsFailure == nil {
+		conf.IsFailure = defaultIsFailure
+	}
+}
+
+func defaultIsFailure(resp *http.Response, err error) bool {
+	return err != nil || (resp != nil && resp.StatusCode >= http.StatusInternalServerError)
+}
+
+// circuitBreaker is closed/open/half-open state machine
+type circuitBreaker struct {
+	name   string
+	conf   *BreakerConfig
+	logger Logger
+	events *eventHub
+
+	mutex    sync.Mutex
+	state    BreakerState
+	openedAt time.Time
+	// generation changes on every state transition, results of requests
+	// allowed in an older generation are ignored
+	generation  uint64
+	consecutive int
+	window      *rollingWindow
+	// probes started and succeeded in half-open state
+	probes       int
+	probeSuccess int
+}
+
+func newCircuitBreaker(name string, conf *BreakerConfig, logger Logger, events *eventHub) *circuitBreaker {
+	return &circuitBreaker{
+		name:   name,
+		conf:   conf,
+		logger: logger,
+		events: events,
+		window: newRollingWindow(conf.Window, breakerWindowBuckets),
+	}
+}
+
+// State return current breaker state
+func (cb *circuitBreaker) State() BreakerState {
+	cb.mutex.Lock()
+	from, to := cb.state, cb.currentState(time.Now())
+	cb.mutex.Unlock()
+	cb.notify(from, to)
+	return to
+}
+
+// allow reports whether request can pass, and reserves a probe in half-open state.
+// The returned generation must be passed to done.
+func (cb *circuitBreaker) allow() (uint64, bool) {
+	now := time.Now()
+	cb.mutex.Lock()
+	from := cb.state
+	state := cb.currentState(now)
+	allowed := true
+	switch state {
+	case BreakerOpen:
+		allowed = false
+	case BreakerHalfOpen:
+		if cb.probes >= cb.conf.HalfOpenRequests {
+			allowed = false
+		} else {
+			cb.probes++
+		}
+	}
+	generation := cb.generation
+	cb.mutex.Unlock()
+	cb.notify(from, state)
+	return generation, allowed
+}
+
+// done records result of a request allowed in generation
+func (cb *circuitBreaker) done(generation uint64, failure bool) {
+	now := time.Now()
+	cb.mutex.Lock()
+	from := cb.state
+	if generation != cb.generation {
+		cb.mutex.Unlock()
+		return
+	}
+
+	switch cb.state {
+	case BreakerClosed:
+		cb.window.add(now, !failure)
+		if failure {
+			cb.consecutive++
+		} else {
+			cb.consecutive = 0
+		}
+		if cb.shouldTrip(now) {
+			cb.setState(BreakerOpen, now)
+		}
+	case BreakerHalfOpen:
+		if failure {
+			cb.setState(BreakerOpen, now)
+		} else {
+			cb.probeSuccess++
+			if cb.probeSuccess >= cb.conf.HalfOpenRequests {
+				cb.setState(BreakerClosed, now)
+			}
+		}
+	}
+	to := cb.state
+	cb.mutex.Unlock()
+	cb.notify(from, to)
+}
+
+// cancel release probe reserved by allow in generation if no request was sent
+func (cb *circuitBreaker) cancel(generation uint64) {
+	cb.mutex.Lock()
+	if generation == cb.generation && cb.state == BreakerHalfOpen && cb.probes > 0 {
+		cb.probes--
+	}
+	cb.mutex.Unlock()
+}
+
+func (cb *circuitBreaker) shouldTrip(now time.Time) bool {
+	if cb.conf.ConsecutiveFailures > 0 && cb.consecutive >= cb.conf.ConsecutiveFailures {
+		return true
+	}
+	if cb.conf.FailureRatio > 0 {
+		success, failure := cb.window.counts(now)
+		total := success + failure
+		if total >= int64(cb.conf.MinRequests) &&
+			float64(failure)/float64(total) >= cb.conf.FailureRatio {
+			return true
+		}
+	}
+	return false
+}
+
+// currentState move open breaker to half-open if OpenTimeout elapsed, must hold mutex
+func (cb *circuitBreaker) currentState(now time.Time) BreakerState {
+	if cb.state == BreakerOpen && now.Sub(cb.openedAt) >= cb.conf.OpenTimeout {
+		cb.setState(BreakerHalfOpen, now)
+	}
+	return cb.state
+}
+
+// setState must hold mutex
+func (cb *circuitBreaker) setState(state BreakerState, now time.Time) {
+	cb.state = state
+	cb.generation++
+	cb.consecutive = 0
+	cb.probes = 0
+	cb.probeSuccess = 0
+	cb.window.reset()
+	if state == BreakerOpen {
+		cb.openedAt = now
+	}
+}
+
+func (cb *circuitBreaker) notify(from, to BreakerState) {
+	if from == to {
+		return
+	}
+	if to == BreakerOpen {
+		cb.logger.Warn("gohttplb breaker opened", "server", cb.name, "from", from.String())
+	} else {
+		cb.logger.Info("gohttplb breaker state changed", "server", cb.name, "from", from.String(), "to", to.String())
+	}
+	if cb.conf.OnStateChange != nil {
+		cb.conf.OnStateChange(cb.name, from, to)
+	}
+	event := Event{Type: EventBreakerStateChanged, Server: cb.name, From: from, To: to}
+	if cb.name == "" {
+		event.Type = EventClusterBreakerStateChanged
+	}
+	cb.events.publish(event)
+}
+
+// rollingWindow counts success and failure in time buckets, not safe for concurrent use
+type rollingWindow struct {
+	width   time.Duration
+	buckets []windowBucket
+}
+
+type windowBucket struct {
+	epoch   int64
+	success int64
+	failure int64
+}
+
+func newRollingWindow(size time.Duration, n int) *rollingWindow {
+	width := size / time.Duration(n)
+	if width <= 0 {
+		width = 1
+	}
+	return &rollingWindow{
+		width:   width,
+		buckets: make([]windowBucket, n),
+	}
+}
+
+func (w *rollingWindow) add(now time.Time, success bool) {
+	epoch := now.UnixNano() / int64(w.width)
+	bucket := &w.buckets[epoch%int64(len(w.buckets))]
+	if bucket.epoch != epoch {
+		*bucket = windowBucket{epoch: epoch}
+	}
+	if success {
+		bucket.success++
+	} else {
+		bucket.failure++
+	}
+}
+
+func (w *rollingWindow) counts(now time.Time) (success, failure int64) {
+	epoch := now.UnixNano() / int64(w.width)
+	for _, bucket := range w.buckets {
+		if epoch-bucket.epoch < int64(len(w.buckets)) {
+			success += bucket.success
+			failure += bucket.failure
+		}
+	}
+	return
+}
+
+func (w *rollingWindow) reset() {
+	for i := range w.buckets {
+		w.buckets[i] = windowBucket{}
+	}
+}
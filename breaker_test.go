@@ -0,0 +1,58 @@
+package gohttplb
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClusterBreakerRecoversAfterPickFailsInHalfOpen(t *testing.T) {
+	t.Run("retry", func(t *testing.T) {
+		testClusterBreakerRecovers(t, nil)
+	})
+	t.Run("hedge", func(t *testing.T) {
+		testClusterBreakerRecovers(t, &HedgeConfig{Delay: time.Second})
+	})
+}
+
+func testClusterBreakerRecovers(t *testing.T, hedge *HedgeConfig) {
+	var failing int32 = 1
+	_, addr := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+
+	lbc, err := New(addr, &LBConfig{
+		Breaker:        &BreakerConfig{ConsecutiveFailures: 1, OpenTimeout: 100 * time.Millisecond},
+		ClusterBreaker: &BreakerConfig{ConsecutiveFailures: 1, OpenTimeout: 20 * time.Millisecond},
+		Hedge:          hedge,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := lbc.Get("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// cluster breaker is half-open while server breaker is still open
+	time.Sleep(40 * time.Millisecond)
+	if _, err = lbc.Get("/"); !errors.Is(err, ErrNoHealthyServers) {
+		t.Fatalf("err = %v, want ErrNoHealthyServers", err)
+	}
+
+	atomic.StoreInt32(&failing, 0)
+	time.Sleep(100 * time.Millisecond)
+	resp, err = lbc.Get("/")
+	if err != nil {
+		t.Fatalf("request after recovery: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+}
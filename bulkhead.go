@@ -0,0 +1,203 @@
+package gohttplb
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Default bulkhead config
+var (
+	DefaultBulkheadQueueTimeout = time.Second
+)
+
+// BulkheadConfig for concurrency limits of LBClient and each server, a request holds
+// its slot until response body is closed
+type BulkheadConfig struct {
+	// MaxConcurrent requests in flight of LBClient
+	// Disabled if 0
+	MaxConcurrent int
+	// MaxConcurrentPerServer requests in flight of each server, saturated servers are skipped
+	// Disabled if 0
+	MaxConcurrentPerServer int
+	// MaxQueue requests waiting for a slot when LBClient or all servers are saturated,
+	// requests fail with ErrConcurrencyLimited if queue is full
+	// Default 0, fail without waiting
+	MaxQueue int
+	// QueueTimeout max time waiting in queue, requests fail with ErrConcurrencyLimited after it
+	// Default 1s
+	QueueTimeout time.Duration
+	// Adaptive adjust limit of each server by observed latency and failures,
+	// MaxConcurrentPerServer is the initial limit if set
+	// Disabled if nil
+	Adaptive *AdaptiveLimitConfig
+}
+
+func setDefaultBulkheadConf(conf *BulkheadConfig) {
+	if conf.QueueTimeout == 0 {
+		conf.QueueTimeout = DefaultBulkheadQueueTimeout
+	}
+	if conf.Adaptive != nil {
+		setDefaultAdaptiveLimitConf(conf.Adaptive, conf.MaxConcurrentPerServer)
+	}
+}
+
+// bulkhead count slots of LBClient and servers, and wake queued requests when a slot is released
+type bulkhead struct {
+	conf *BulkheadConfig
+
+	mutex  sync.Mutex
+	active int
+	queued int
+	// wake is closed and replaced when a slot is released
+	wake chan struct{}
+}
+
+func newBulkhead(conf *BulkheadConfig) *bulkhead {
+	return &bulkhead{
+		conf: conf,
+		wake: make(chan struct{}),
+	}
+}
+
+// acquire take a LBClient slot
+func (b *bulkhead) acquire() bool {
+	if b == nil || b.conf.MaxConcurrent <= 0 {
+		return true
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.active >= b.conf.MaxConcurrent {
+		return false
+	}
+	b.active++
+	return true
+}
+
+// serverLimited reports whether servers have concurrency limit
+func (b *bulkhead) serverLimited() bool {
+	return b.conf.MaxConcurrentPerServer > 0 || b.conf.Adaptive != nil
+}
+
+// acquireServer take a slot of node
+func (b *bulkhead) acquireServer(n *node) bool {
+	if b == nil || !b.serverLimited() {
+		return true
+	}
+	max := int64(b.conf.MaxConcurrentPerServer)
+	if n.adaptive != nil {
+		max = int64(n.adaptive.getLimit())
+	}
+	for {
+		active := atomic.LoadInt64(&n.active)
+		if active >= max {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&n.active, active, active+1) {
+			return true
+		}
+	}
+}
+
+// release return LBClient slot, and slot of node if not nil
+func (b *bulkhead) release(n *node) {
+	if b == nil {
+		return
+	}
+	if n != nil && b.serverLimited() {
+		atomic.AddInt64(&n.active, -1)
+	}
+	b.mutex.Lock()
+	if b.conf.MaxConcurrent > 0 {
+		b.active--
+	}
+	close(b.wake)
+	b.wake = make(chan struct{})
+	b.mutex.Unlock()
+}
+
+// releaseClient return LBClient slot of failed pick without waking queued requests,
+// the failed pick would otherwise wake itself
+func (b *bulkhead) releaseClient() {
+	if b.conf.MaxConcurrent > 0 {
+		b.mutex.Lock()
+		b.active--
+		b.mutex.Unlock()
+	}
+}
+
+// releaseServer return slot of node only
+func (b *bulkhead) releaseServer(n *node) {
+	if b != nil && b.serverLimited() {
+		atomic.AddInt64(&n.active, -1)
+	}
+}
+
+func (b *bulkhead) wakeChan() <-chan struct{} {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.wake
+}
+
+func (b *bulkhead) enqueue() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.queued >= b.conf.MaxQueue {
+		return false
+	}
+	b.queued++
+	return true
+}
+
+func (b *bulkhead) dequeue() {
+	b.mutex.Lock()
+	b.queued--
+	b.mutex.Unlock()
+}
+
+// wait call pick until it is not saturated, waiting in queue for released slots
+func (b *bulkhead) wait(ctx context.Context, pick func() (*node, uint64, error)) (*node, uint64, error) {
+	var timer *time.Timer
+	for {
+		// get wake before pick to not miss slots released meanwhile
+		wake := b.wakeChan()
+		n, generation, err := pick()
+		if err != ErrConcurrencyLimited {
+			return n, generation, err
+		}
+		if timer == nil {
+			if !b.enqueue() {
+				return nil, 0, err
+			}
+			defer b.dequeue()
+			timer = time.NewTimer(b.conf.QueueTimeout)
+			defer timer.Stop()
+		}
+		select {
+		case <-wake:
+		case <-timer.C:
+			return nil, 0, err
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		}
+	}
+}
+
+// releaseOnClose release slots of n when response body is closed, or at once if request failed
+func (r *R) releaseOnClose(resp *http.Response, err error, n *node) *http.Response {
+	if r.bulkhead == nil {
+		return resp
+	}
+	if err != nil {
+		r.bulkhead.release(n)
+		return resp
+	}
+	var once sync.Once
+	return withCancelBody(resp, func() {
+		once.Do(func() {
+			r.bulkhead.release(n)
+		})
+	})
+}
@@ -0,0 +1,177 @@
+package gohttplb
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// bulkheadActive return active requests of LBClient and servers holding bulkhead slots
+func bulkheadActive(lbc *LBClient) (client int, servers int64) {
+	lbc.R.bulkhead.mutex.Lock()
+	client = lbc.R.bulkhead.active
+	lbc.R.bulkhead.mutex.Unlock()
+	for _, n := range lbc.R.nodes {
+		servers += atomic.LoadInt64(&n.active)
+	}
+	return
+}
+
+func TestBulkheadQueueOverflow(t *testing.T) {
+	_, addr := newTestServer(t, okHandler)
+	lbc, err := New(addr, &LBConfig{Bulkhead: &BulkheadConfig{MaxConcurrent: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	held, err := lbc.Get("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Body.Close()
+	if _, err = lbc.Get("/"); !errors.Is(err, ErrConcurrencyLimited) {
+		t.Fatalf("err = %v, want ErrConcurrencyLimited", err)
+	}
+}
+
+func TestBulkheadQueue(t *testing.T) {
+	_, addr := newTestServer(t, okHandler)
+	lbc, err := New(addr, &LBConfig{Bulkhead: &BulkheadConfig{MaxConcurrent: 1, MaxQueue: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	held, err := lbc.Get("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	queued := make(chan error, 1)
+	go func() {
+		resp, err := lbc.Get("/")
+		if err == nil {
+			resp.Body.Close()
+		}
+		queued <- err
+	}()
+	// wait for the request to be queued, then the queue is full
+	for deadline := time.Now().Add(time.Second); ; {
+		lbc.R.bulkhead.mutex.Lock()
+		n := lbc.R.bulkhead.queued
+		lbc.R.bulkhead.mutex.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("request not queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err = lbc.Get("/"); !errors.Is(err, ErrConcurrencyLimited) {
+		t.Fatalf("err = %v, want ErrConcurrencyLimited when queue is full", err)
+	}
+
+	held.Body.Close()
+	select {
+	case err := <-queued:
+		if err != nil {
+			t.Fatalf("queued request: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("queued request not woken after slot released")
+	}
+}
+
+func TestBulkheadQueueTimeout(t *testing.T) {
+	_, addr := newTestServer(t, okHandler)
+	lbc, err := New(addr, &LBConfig{Bulkhead: &BulkheadConfig{
+		MaxConcurrent: 1,
+		MaxQueue:      1,
+		QueueTimeout:  30 * time.Millisecond,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	held, err := lbc.Get("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Body.Close()
+	start := time.Now()
+	if _, err = lbc.Get("/"); !errors.Is(err, ErrConcurrencyLimited) {
+		t.Fatalf("err = %v, want ErrConcurrencyLimited", err)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Fatalf("failed after %s, want QueueTimeout 30ms", elapsed)
+	}
+}
+
+func TestBulkheadSkipsSaturatedServer(t *testing.T) {
+	_, addr := newTestServer(t, okHandler)
+	_, other := newTestServer(t, okHandler)
+	lbc, err := New(addr+","+other, &LBConfig{Bulkhead: &BulkheadConfig{MaxConcurrentPerServer: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var held []*http.Response
+	for i := 0; i < 2; i++ {
+		resp, err := lbc.Get("/")
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		held = append(held, resp)
+	}
+	for _, stats := range lbc.Stats() {
+		if stats.Requests != 1 {
+			t.Fatalf("server %s requests = %d, want 1", stats.Server, stats.Requests)
+		}
+	}
+	if _, err = lbc.Get("/"); !errors.Is(err, ErrConcurrencyLimited) {
+		t.Fatalf("err = %v, want ErrConcurrencyLimited", err)
+	}
+	for _, resp := range held {
+		resp.Body.Close()
+	}
+}
+
+func TestBulkheadReleasesSlots(t *testing.T) {
+	_, addr := newTestServer(t, okHandler)
+	lbc, err := New(addr+","+closedAddr(t), &LBConfig{Bulkhead: &BulkheadConfig{
+		MaxConcurrent:          2,
+		MaxConcurrentPerServer: 1,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 4; i++ {
+		resp, err := lbc.Get("/")
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		if client, servers := bulkheadActive(lbc); client != 1 || servers != 1 {
+			t.Fatalf("request %d: active %d, servers %d before body closed, want 1 1", i, client, servers)
+		}
+		resp.Body.Close()
+		resp.Body.Close()
+		if client, servers := bulkheadActive(lbc); client != 0 || servers != 0 {
+			t.Fatalf("request %d: active %d, servers %d after body closed, want 0 0", i, client, servers)
+		}
+	}
+
+	// slots taken by a failed pick are returned
+	for _, stats := range lbc.Stats() {
+		if err = lbc.Eject(stats.Server, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = lbc.Get("/"); !errors.Is(err, ErrNoHealthyServers) {
+		t.Fatalf("err = %v, want ErrNoHealthyServers", err)
+	}
+	if client, servers := bulkheadActive(lbc); client != 0 || servers != 0 {
+		t.Fatalf("active %d, servers %d after failed pick, want 0 0", client, servers)
+	}
+}
@@ -1,9 +1,9 @@
 package gohttplb
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -12,8 +12,17 @@ import (
 
 // Errors
 var (
-	ErrInvalidAddr         = errors.New("invalid addr")
-	ErrInvalidAddrWeighted = errors.New("invalid addr weighted")
+	ErrInvalidAddr          = errors.New("invalid addr")
+	ErrInvalidAddrWeighted  = errors.New("invalid addr weighted")
+	ErrNoHealthyServers     = errors.New("no healthy servers")
+	ErrCircuitOpen          = errors.New("circuit open")
+	ErrAllServersFailed     = errors.New("all servers failed")
+	ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+	ErrRateLimited          = errors.New("rate limited")
+	ErrConcurrencyLimited   = errors.New("concurrency limited")
+	ErrBodyNotReplayable    = errors.New("body not replayable")
+	ErrServerNotFound       = errors.New("server not found")
+	ErrInvalidWeight        = errors.New("invalid weight")
 )
 
 // Default config
@@ -33,12 +42,19 @@ var (
 	DefaultContentType = "application/json; charset=utf-8"
 	HeaderAccept       = "Accept"
 	DefaultAccept      = "application/json"
+	FormContentType    = "application/x-www-form-urlencoded"
 )
 
 func setDefaultConf(conf *LBConfig) {
 	if conf.Retry == 0 {
 		conf.Retry = DefaultRetry
 	}
+	if conf.Logger == nil {
+		conf.Logger = noopLogger{}
+	}
+	if conf.ResponseParser == nil {
+		conf.ResponseParser = &DefaultResponseParser{}
+	}
 	if conf.Transport == nil {
 		conf.Transport = DefaultTransport
 	}
@@ -46,11 +62,33 @@ func setDefaultConf(conf *LBConfig) {
 		conf.ClientTimeout = DefaultClientTimeout
 	}
 	if conf.Client == nil {
-		conf.Client = &http.Client{
-			Transport: conf.Transport,
-			Timeout:   conf.ClientTimeout,
+		conf.Client = &http.Client{Transport: conf.Transport}
+		// per attempt and request deadlines replace http.Client.Timeout, which would cut them short
+		if conf.PerTryTimeout == 0 && conf.RequestTimeout == 0 {
+			conf.Client.Timeout = conf.ClientTimeout
 		}
 	}
+	if conf.Breaker != nil {
+		setDefaultBreakerConf(conf.Breaker)
+	}
+	if conf.ClusterBreaker != nil {
+		setDefaultBreakerConf(conf.ClusterBreaker)
+	}
+	if conf.RetryBudget != nil {
+		setDefaultRetryBudgetConf(conf.RetryBudget)
+	}
+	if conf.Hedge != nil {
+		setDefaultHedgeConf(conf.Hedge)
+	}
+	if conf.Bulkhead != nil {
+		setDefaultBulkheadConf(conf.Bulkhead)
+	}
+	if conf.Throttle != nil {
+		setDefaultThrottleConf(conf.Throttle)
+	}
+	if conf.Compression != nil {
+		setDefaultCompressionConf(conf.Compression)
+	}
 }
 
 // LBConfig for init LBClient config
@@ -62,8 +100,12 @@ type LBConfig struct {
 	// Most retries: len(servers) * Retry
 	// Default 1
 	Retry int
-	// ResponseParser response parser
-	// Will auto parse response if set, and must use JPGet, JPPost...
+	// RetryStatus response status codes retried on other servers,
+	// last response is returned if retries exhausted
+	// Default none
+	RetryStatus []int
+	// ResponseParser response parser used by JPGet, JPPost..., must be safe for concurrent use
+	// Default DefaultResponseParser
 	ResponseParser ResponseParser
 	// Client for http request
 	// If not set, will new with Transport and ClientTimeout
@@ -71,9 +113,60 @@ type LBConfig struct {
 	// Transport for http client
 	// Default DefaultTransport
 	Transport *http.Transport
-	// ClientTimeout for `http.Client.Timeout`
+	// ClientTimeout for `http.Client.Timeout`, applies to each attempt,
+	// not used if PerTryTimeout or RequestTimeout set
 	// Default 10s
 	ClientTimeout time.Duration
+	// PerTryTimeout deadline of each attempt, can be overridden by WithPerTryTimeout
+	// Disabled if 0
+	PerTryTimeout time.Duration
+	// RequestTimeout total deadline of request including all retries,
+	// can be overridden by WithRequestTimeout
+	// Disabled if 0
+	RequestTimeout time.Duration
+	// Breaker per server circuit breaker, open circuit servers are skipped
+	// without spending a retry
+	// Disabled if nil
+	Breaker *BreakerConfig
+	// ClusterBreaker circuit breaker over all servers, counts every attempt,
+	// requests fail fast with ErrCircuitOpen when it is open
+	// Disabled if nil
+	ClusterBreaker *BreakerConfig
+	// RetryBudget limit retries shared by all requests, return last error
+	// instead of retrying when budget exhausted
+	// Disabled if nil
+	RetryBudget *RetryBudgetConfig
+	// RateLimit client side rate limits of LBClient and each server, requests fail with
+	// ErrRateLimited or wait for token
+	// Disabled if nil
+	RateLimit *RateLimitConfig
+	// Bulkhead limit requests in flight of LBClient and each server, requests queue or fail
+	// with ErrConcurrencyLimited when saturated
+	// Disabled if nil
+	Bulkhead *BulkheadConfig
+	// Throttle skip servers responding 429 or 503 with Retry-After until it elapsed,
+	// requests fail with *ThrottledError when all servers are throttled
+	// Disabled if nil
+	Throttle *ThrottleConfig
+	// Hedge send idempotent requests to another server if first one is slow,
+	// hedged requests spend retries
+	// Disabled if nil
+	Hedge *HedgeConfig
+	// Compression compress request body and decompress response body
+	// Disabled if nil
+	Compression *CompressionConfig
+	// Middlewares run around each attempt, use AttemptFromContext to get server and attempt number
+	Middlewares []Middleware
+	// CallMiddlewares run once around all attempts of a request
+	CallMiddlewares []Middleware
+	// Logger for server selection, retries, failures and breaker transitions,
+	// use NewSlogLogger for log/slog
+	// Default no-op
+	Logger Logger
+	// Tracer create span for each request and child span for each attempt,
+	// W3C trace context of attempt span is sent in traceparent and tracestate headers
+	// Disabled if nil
+	Tracer Tracer
 }
 
 // LBClient ...
@@ -100,7 +193,7 @@ func New(addr string, config ...*LBConfig) (*LBClient, error) {
 	if len(addrs) == 0 {
 		return nil, ErrInvalidAddr
 	}
-	log.Println("addrs:", addrs)
+	conf.Logger.Debug("gohttplb servers", "servers", addrs)
 
 	strategy, serverWeighteds, err := determineStrategy(addrs)
 	if err != nil {
@@ -165,6 +258,13 @@ func (lbc *LBClient) parseParamsHeaders(paramsHeaders []map[string]string) (para
 	return
 }
 
+// Do request with context, ctx deadline and WithRequestTimeout limit the whole request,
+// WithPerTryTimeout limit each attempt
+func (lbc *LBClient) Do(ctx context.Context, method, path string, body []byte, paramsHeaders ...map[string]string) (resp *http.Response, err error) {
+	params, headers := lbc.parseParamsHeaders(paramsHeaders)
+	return lbc.R.doRequestContext(ctx, method, path, params, headers, body)
+}
+
 // Get method request
 func (lbc *LBClient) Get(path string, paramsHeaders ...map[string]string) (resp *http.Response, err error) {
 	params, headers := lbc.parseParamsHeaders(paramsHeaders)
@@ -299,6 +399,14 @@ func (lbc *LBClient) JPPatch(path string, body []byte, paramsHeaders ...map[stri
 	return lbc.R.parsePatch(http.MethodPatch, path, params, headers, body)
 }
 
+// RetryBudgetStats return retry budget counters, zero if RetryBudget not set
+func (lbc *LBClient) RetryBudgetStats() RetryBudgetStats {
+	if lbc.R.budget == nil {
+		return RetryBudgetStats{}
+	}
+	return lbc.R.budget.stats()
+}
+
 // PResponse parse response use custom or default ResponseParser
 func (lbc *LBClient) PResponse(resp *http.Response, rps ...ResponseParser) (int, []byte, error) {
 	var rp ResponseParser
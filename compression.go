@@ -0,0 +1,260 @@
+package gohttplb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Compression headers
+var (
+	HeaderContentEncoding = "Content-Encoding"
+	HeaderAcceptEncoding  = "Accept-Encoding"
+)
+
+// Default compression config
+var (
+	DefaultCompressionMinSize int64 = 1024
+)
+
+// Encoder wrap w to compress data written to it
+type Encoder func(w io.Writer) (io.WriteCloser, error)
+
+// Decoder wrap r to decompress data read from it
+type Decoder func(r io.Reader) (io.ReadCloser, error)
+
+var (
+	codecsMutex sync.RWMutex
+	encoders    = map[string]Encoder{
+		"gzip": func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(w), nil
+		},
+		"deflate": func(w io.Writer) (io.WriteCloser, error) {
+			return zlib.NewWriter(w), nil
+		},
+	}
+	decoders = map[string]Decoder{
+		"gzip": func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+		"deflate": decodeDeflate,
+	}
+	// decoderNames keep registration order for Accept-Encoding
+	decoderNames = []string{"gzip", "deflate"}
+)
+
+// RegisterEncoder register request body encoder for Content-Encoding, e.g. zstd:
+//
+//	gohttplb.RegisterEncoder("zstd", func(w io.Writer) (io.WriteCloser, error) {
+//		return zstd.NewWriter(w)
+//	})
+func RegisterEncoder(encoding string, encoder Encoder) {
+	codecsMutex.Lock()
+	encoders[encoding] = encoder
+	codecsMutex.Unlock()
+}
+
+// RegisterDecoder register response body decoder for Content-Encoding, e.g. br:
+//
+//	gohttplb.RegisterDecoder("br", func(r io.Reader) (io.ReadCloser, error) {
+//		return ioutil.NopCloser(brotli.NewReader(r)), nil
+//	})
+func RegisterDecoder(encoding string, decoder Decoder) {
+	codecsMutex.Lock()
+	if _, ok := decoders[encoding]; !ok {
+		decoderNames = append(decoderNames, encoding)
+	}
+	decoders[encoding] = decoder
+	codecsMutex.Unlock()
+}
+
+func getEncoder(encoding string) (Encoder, bool) {
+	codecsMutex.RLock()
+	defer codecsMutex.RUnlock()
+	encoder, ok := encoders[encoding]
+	return encoder, ok
+}
+
+func getDecoder(encoding string) (Decoder, bool) {
+	codecsMutex.RLock()
+	defer codecsMutex.RUnlock()
+	decoder, ok := decoders[encoding]
+	return decoder, ok
+}
+
+func acceptEncoding() string {
+	codecsMutex.RLock()
+	defer codecsMutex.RUnlock()
+	return strings.Join(decoderNames, ", ")
+}
+
+// decodeDeflate decode zlib format, or raw deflate sent by some servers
+func decodeDeflate(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// CompressionConfig for request and response compression
+type CompressionConfig struct {
+	// RequestEncoding compress request body with it, "gzip", "deflate" or registered by RegisterEncoder
+	// Disabled if empty
+	RequestEncoding string
+	// MinSize request body smaller than it is not compressed, body with unknown size is always compressed
+	// Default 1024
+	MinSize int64
+}
+
+func setDefaultCompressionConf(conf *CompressionConfig) {
+	if conf.MinSize == 0 {
+		conf.MinSize = DefaultCompressionMinSize
+	}
+}
+
+// compressBody compress request body once for all attempts
+func (r *R) compressBody(rA *rArgs) error {
+	if r.Compression == nil || r.Compression.RequestEncoding == "" || rA.body == nil ||
+		rA.headers.Get(HeaderContentEncoding) != "" {
+		return nil
+	}
+	if rA.body.length >= 0 && rA.body.length < r.Compression.MinSize {
+		return nil
+	}
+	encoder, ok := getEncoder(r.Compression.RequestEncoding)
+	if !ok {
+		return nil
+	}
+
+	body, err := compressRequestBody(rA.body, encoder)
+	if err != nil {
+		return err
+	}
+	rA.body = body
+	rA.headers.Set(HeaderContentEncoding, r.Compression.RequestEncoding)
+	return nil
+}
+
+func compressRequestBody(body *requestBody, encoder Encoder) (*requestBody, error) {
+	// in memory body is compressed to bytes to keep Content-Length
+	if body.data != nil {
+		var buf bytes.Buffer
+		if err := encode(&buf, bytes.NewReader(body.data), encoder); err != nil {
+			return nil, err
+		}
+		return bytesBody(buf.Bytes()), nil
+	}
+
+	if body.replayable() {
+		return funcBody(func() (io.ReadCloser, error) {
+			reader, err := body.getBody()
+			if err != nil {
+				return nil, err
+			}
+			return encodeReader(reader, encoder), nil
+		}, -1), nil
+	}
+	return readerBody(&lazyReader{open: func() io.ReadCloser {
+		return encodeReader(body.reader, encoder)
+	}}), nil
+}
+
+func encode(w io.Writer, r io.Reader, encoder Encoder) error {
+	ew, err := encoder(w)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(ew, r); err != nil {
+		ew.Close()
+		return err
+	}
+	return ew.Close()
+}
+
+// encodeReader return reader of data read from r and compressed by a goroutine
+func encodeReader(r io.Reader, encoder Encoder) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		err := encode(pw, r, encoder)
+		if closer, ok := r.(io.Closer); ok {
+			closer.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// lazyReader open reader on first Read
+type lazyReader struct {
+	open   func() io.ReadCloser
+	reader io.ReadCloser
+}
+
+func (r *lazyReader) Read(p []byte) (int, error) {
+	if r.reader == nil {
+		r.reader = r.open()
+	}
+	return r.reader.Read(p)
+}
+
+func (r *lazyReader) Close() error {
+	if r.reader == nil {
+		return nil
+	}
+	return r.reader.Close()
+}
+
+// setAcceptEncoding ask for registered encodings if compression enabled and
+// Accept-Encoding not set, response is decoded by decodeResponse
+func (r *R) setAcceptEncoding(req *http.Request) {
+	if r.Compression != nil && req.Header.Get(HeaderAcceptEncoding) == "" {
+		req.Header.Set(HeaderAcceptEncoding, acceptEncoding())
+	}
+}
+
+// decodeResponse replace compressed response body with decompressed one
+func decodeResponse(resp *http.Response) error {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get(HeaderContentEncoding)))
+	if encoding == "" || encoding == "identity" {
+		return nil
+	}
+	decoder, ok := getDecoder(encoding)
+	if !ok {
+		return nil
+	}
+
+	body, err := decoder(resp.Body)
+	switch err {
+	case nil:
+		resp.Body = &decodedBody{ReadCloser: body, raw: resp.Body}
+	case io.EOF:
+		// empty body
+	default:
+		resp.Body.Close()
+		return err
+	}
+	resp.Header.Del(HeaderContentEncoding)
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// decodedBody close both decoder and raw body
+type decodedBody struct {
+	io.ReadCloser
+	raw io.ReadCloser
+}
+
+func (body *decodedBody) Close() error {
+	body.ReadCloser.Close()
+	return body.raw.Close()
+}
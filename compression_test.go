@@ -0,0 +1,170 @@
+package gohttplb
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// echoEncodedHandler decode request body and echo it with its Content-Encoding
+func echoEncodedHandler(w http.ResponseWriter, req *http.Request) {
+	var body io.Reader = req.Body
+	switch encoding := req.Header.Get(HeaderContentEncoding); encoding {
+	case "":
+	case "gzip":
+		zr, err := gzip.NewReader(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = zr
+	case "deflate":
+		zr, err := zlib.NewReader(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = zr
+	default:
+		http.Error(w, "unknown encoding "+encoding, http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("X-Request-Encoding", req.Header.Get(HeaderContentEncoding))
+	io.Copy(w, body)
+}
+
+func TestRequestCompression(t *testing.T) {
+	_, addr := newTestServer(t, echoEncodedHandler)
+	large := strings.Repeat("gohttplb ", 256)
+
+	tests := []struct {
+		name         string
+		encoding     string
+		body         func(rb *RequestBuilder) *RequestBuilder
+		want         string
+		wantEncoding string
+	}{
+		{"gzip", "gzip", func(rb *RequestBuilder) *RequestBuilder {
+			return rb.Body([]byte(large))
+		}, large, "gzip"},
+		{"deflate", "deflate", func(rb *RequestBuilder) *RequestBuilder {
+			return rb.Body([]byte(large))
+		}, large, "deflate"},
+		{"below min size", "gzip", func(rb *RequestBuilder) *RequestBuilder {
+			return rb.Body([]byte("small"))
+		}, "small", ""},
+		{"replayable reader below min size", "gzip", func(rb *RequestBuilder) *RequestBuilder {
+			return rb.BodyReader(strings.NewReader("small"))
+		}, "small", ""},
+		{"streaming reader", "gzip", func(rb *RequestBuilder) *RequestBuilder {
+			return rb.BodyReader(iotest.OneByteReader(strings.NewReader("small")))
+		}, "small", "gzip"},
+		{"body func", "gzip", func(rb *RequestBuilder) *RequestBuilder {
+			return rb.BodyFunc(func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(strings.NewReader("small")), nil
+			}, -1)
+		}, "small", "gzip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lbc, err := New(addr, &LBConfig{
+				Compression: &CompressionConfig{RequestEncoding: tt.encoding},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := tt.body(lbc.NewRequest().Method(http.MethodPost)).Do(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			data, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d: %s", resp.StatusCode, data)
+			}
+			if encoding := resp.Header.Get("X-Request-Encoding"); encoding != tt.wantEncoding {
+				t.Fatalf("request encoding = %q, want %q", encoding, tt.wantEncoding)
+			}
+			if string(data) != tt.want {
+				t.Fatalf("body = %q, want %q", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseDecompression(t *testing.T) {
+	_, addr := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		if accept := req.Header.Get(HeaderAcceptEncoding); accept != "gzip, deflate" {
+			http.Error(w, "Accept-Encoding "+accept, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set(HeaderContentEncoding, "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte("hello"))
+		zw.Close()
+	})
+	lbc, err := New(addr, &LBConfig{Compression: &CompressionConfig{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := lbc.Get("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" || !resp.Uncompressed {
+		t.Fatalf("body = %q, uncompressed %v, want decoded hello", data, resp.Uncompressed)
+	}
+}
+
+func TestDecodeDeflate(t *testing.T) {
+	tests := []struct {
+		name   string
+		writer func(w io.Writer) io.WriteCloser
+	}{
+		{"zlib", func(w io.Writer) io.WriteCloser {
+			return zlib.NewWriter(w)
+		}},
+		{"raw deflate", func(w io.Writer) io.WriteCloser {
+			fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+			return fw
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := tt.writer(&buf)
+			w.Write([]byte("hello deflate"))
+			w.Close()
+
+			r, err := decodeDeflate(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			data, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != "hello deflate" {
+				t.Fatalf("data = %q, want %q", data, "hello deflate")
+			}
+		})
+	}
+}
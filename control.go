@@ -0,0 +1,45 @@
+package gohttplb
+
+import "time"
+
+// Eject take server out of rotation for duration, until Restore if duration is 0.
+// Server scheme can be omitted.
+func (lbc *LBClient) Eject(server string, duration time.Duration) error {
+	n, err := lbc.R.node(server)
+	if err != nil {
+		return err
+	}
+	n.eject(duration)
+	lbc.R.Logger.Warn("gohttplb server ejected", "server", n.server, "duration", duration)
+	lbc.R.events.publish(Event{Type: EventServerEjected, Server: n.server, Duration: duration})
+	return nil
+}
+
+// Drain stop sending new requests to server until Restore, in-flight requests are not affected
+func (lbc *LBClient) Drain(server string) error {
+	n, err := lbc.R.node(server)
+	if err != nil {
+		return err
+	}
+	n.drain()
+	lbc.R.Logger.Warn("gohttplb server draining", "server", n.server)
+	lbc.R.events.publish(Event{Type: EventServerDraining, Server: n.server})
+	return nil
+}
+
+// Restore put ejected or draining server back to rotation
+func (lbc *LBClient) Restore(server string) error {
+	n, err := lbc.R.node(server)
+	if err != nil {
+		return err
+	}
+	n.restore()
+	lbc.R.Logger.Info("gohttplb server restored", "server", n.server)
+	lbc.R.events.publish(Event{Type: EventServerRestored, Server: n.server})
+	return nil
+}
+
+// SetWeight change weight of server, strategy becomes StrategyWeightedRoundRobin
+func (lbc *LBClient) SetWeight(server string, weight int) error {
+	return lbc.R.setWeight(server, weight)
+}
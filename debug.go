@@ -0,0 +1,267 @@
+package gohttplb
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultDebugAuthScheme scheme of WWW-Authenticate challenge sent by DebugHandler
+var DefaultDebugAuthScheme = "Basic"
+
+// DebugConfig for DebugHandler
+type DebugConfig struct {
+	// Authorize authorize POST actions, all actions are rejected if nil.
+	// Cross-site POST is rejected before it, so cached basic auth credentials of browsers
+	// can not be used by other sites
+	Authorize func(req *http.Request) bool
+	// AuthScheme scheme of WWW-Authenticate challenge of unauthorized POST, "Bearer" for BearerTokenAuthorizer.
+	// Action forms of the HTML page work with Basic only, browsers can not send a bearer token
+	// Default "Basic"
+	AuthScheme string
+}
+
+func setDefaultDebugConf(conf *DebugConfig) {
+	if conf.AuthScheme == "" {
+		conf.AuthScheme = DefaultDebugAuthScheme
+	}
+}
+
+// BearerTokenAuthorizer authorize requests with `Authorization: Bearer token` header,
+// all requests are rejected if token is empty
+func BearerTokenAuthorizer(token string) func(req *http.Request) bool {
+	return func(req *http.Request) bool {
+		got, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		return ok && token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+	}
+}
+
+// BasicAuthAuthorizer authorize requests with basic auth, browsers prompt for it,
+// all requests are rejected if password is empty
+func BasicAuthAuthorizer(username, password string) func(req *http.Request) bool {
+	return func(req *http.Request) bool {
+		u, p, ok := req.BasicAuth()
+		return ok && password != "" && subtle.ConstantTimeCompare([]byte(u), []byte(username)) == 1 &&
+			subtle.ConstantTimeCompare([]byte(p), []byte(password)) == 1
+	}
+}
+
+// sameOrigin reports whether browser request is sent by page of the same origin,
+// requests without Sec-Fetch-Site, Origin and Referer headers are not from browsers and allowed
+func sameOrigin(req *http.Request) bool {
+	switch site := req.Header.Get("Sec-Fetch-Site"); site {
+	case "":
+	case "same-origin", "none":
+		return true
+	default:
+		return false
+	}
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		origin = req.Header.Get("Referer")
+	}
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == req.Host
+}
+
+// debugServer is server info rendered by DebugHandler
+type debugServer struct {
+	Server         string     `json:"server"`
+	Weight         int        `json:"weight"`
+	Limit          int        `json:"concurrency_limit,omitempty"`
+	State          string     `json:"state"`
+	Ejected        bool       `json:"ejected"`
+	Draining       bool       `json:"draining"`
+	Requests       uint64     `json:"requests"`
+	Errors         uint64     `json:"errors"`
+	InFlight       int64      `json:"in_flight"`
+	EWMALatency    string     `json:"ewma_latency"`
+	LastError      string     `json:"last_error,omitempty"`
+	LastErrorTime  *time.Time `json:"last_error_time,omitempty"`
+	ThrottledUntil *time.Time `json:"throttled_until,omitempty"`
+}
+
+// debugInfo is client info rendered by DebugHandler
+type debugInfo struct {
+	Strategy     string           `json:"strategy"`
+	ClusterState string           `json:"cluster_state,omitempty"`
+	RetryBudget  RetryBudgetStats `json:"retry_budget"`
+	Servers      []debugServer    `json:"servers"`
+}
+
+func (lbc *LBClient) debugInfo() debugInfo {
+	info := debugInfo{
+		Strategy:    lbc.R.getStrategy().String(),
+		RetryBudget: lbc.RetryBudgetStats(),
+	}
+	if lbc.R.cluster != nil {
+		info.ClusterState = lbc.R.cluster.State().String()
+	}
+	for _, stats := range lbc.Stats() {
+		server := debugServer{
+			Server:      stats.Server,
+			Weight:      stats.Weight,
+			Limit:       stats.ConcurrencyLimit,
+			State:       stats.State.String(),
+			Ejected:     stats.Ejected,
+			Draining:    stats.Draining,
+			Requests:    stats.Requests,
+			Errors:      stats.Errors,
+			InFlight:    stats.InFlight,
+			EWMALatency: stats.EWMALatency.String(),
+		}
+		if stats.LastError != nil {
+			server.LastError = stats.LastError.Error()
+			server.LastErrorTime = &stats.LastErrorTime
+		}
+		if !stats.ThrottledUntil.IsZero() {
+			server.ThrottledUntil = &stats.ThrottledUntil
+		}
+		info.Servers = append(info.Servers, server)
+	}
+	return info
+}
+
+// DebugHandler return http.Handler showing servers, strategy, stats and health in HTML,
+// or JSON if `?format=json` or Accept is application/json. Mount it like:
+//
+//	mux.Handle("/debug/gohttplb", lbclient.DebugHandler(gohttplb.DebugConfig{
+//		Authorize:  gohttplb.BearerTokenAuthorizer(token),
+//		AuthScheme: "Bearer",
+//	}))
+//
+// Authorized POST form actions change servers at runtime:
+//
+//	action=eject&server=127.0.0.1:8080[&duration=1m]
+//	action=drain&server=127.0.0.1:8080
+//	action=restore&server=127.0.0.1:8080
+//	action=weight&server=127.0.0.1:8080&weight=3
+func (lbc *LBClient) DebugHandler(conf DebugConfig) http.Handler {
+	setDefaultDebugConf(&conf)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet, http.MethodHead:
+			lbc.serveDebugInfo(w, req)
+		case http.MethodPost:
+			if !sameOrigin(req) {
+				http.Error(w, "cross-site request", http.StatusForbidden)
+				return
+			}
+			if conf.Authorize == nil || !conf.Authorize(req) {
+				w.Header().Set("WWW-Authenticate", conf.AuthScheme+` realm="gohttplb"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			lbc.serveDebugAction(w, req)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func wantJSON(req *http.Request) bool {
+	return req.URL.Query().Get("format") == "json" ||
+		strings.Contains(req.Header.Get(HeaderAccept), "application/json")
+}
+
+func (lbc *LBClient) serveDebugInfo(w http.ResponseWriter, req *http.Request) {
+	info := lbc.debugInfo()
+	if wantJSON(req) {
+		w.Header().Set(HeaderContentType, DefaultContentType)
+		json.NewEncoder(w).Encode(info)
+		return
+	}
+	w.Header().Set(HeaderContentType, "text/html; charset=utf-8")
+	debugTemplate.Execute(w, info)
+}
+
+func (lbc *LBClient) serveDebugAction(w http.ResponseWriter, req *http.Request) {
+	server := req.FormValue("server")
+	var err error
+	switch req.FormValue("action") {
+	case "eject":
+		var duration time.Duration
+		if d := req.FormValue("duration"); d != "" {
+			if duration, err = time.ParseDuration(d); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		err = lbc.Eject(server, duration)
+	case "drain":
+		err = lbc.Drain(server)
+	case "restore":
+		err = lbc.Restore(server)
+	case "weight":
+		weight, convErr := strconv.Atoi(req.FormValue("weight"))
+		if convErr != nil {
+			http.Error(w, ErrInvalidWeight.Error(), http.StatusBadRequest)
+			return
+		}
+		err = lbc.SetWeight(server, weight)
+	default:
+		http.Error(w, "invalid action", http.StatusBadRequest)
+		return
+	}
+
+	switch err {
+	case nil:
+	case ErrServerNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if wantJSON(req) {
+		lbc.serveDebugInfo(w, req)
+		return
+	}
+	http.Redirect(w, req, req.URL.Path, http.StatusSeeOther)
+}
+
+var debugTemplate = template.Must(template.New("debug").Parse(`<!DOCTYPE html>
+<html>
+<head><title>gohttplb</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+</style>
+</head>
+<body>
+<h1>gohttplb</h1>
+<p>Strategy: {{.Strategy}}{{if .ClusterState}} | Cluster breaker: {{.ClusterState}}{{end}}
+| Retries: {{.RetryBudget.Retries}} | Retries denied: {{.RetryBudget.Denied}}</p>
+<table>
+<tr><th>Server</th><th>Weight</th><th>Breaker</th><th>Ejected</th><th>Draining</th><th>Requests</th>
+<th>Errors</th><th>In flight</th><th>EWMA latency</th><th>Last error</th><th>Actions</th></tr>
+{{range .Servers}}
+<tr>
+<td>{{.Server}}</td><td>{{.Weight}}</td><td>{{.State}}</td><td>{{.Ejected}}</td><td>{{.Draining}}</td>
+<td>{{.Requests}}</td><td>{{.Errors}}</td><td>{{.InFlight}}</td><td>{{.EWMALatency}}</td>
+<td>{{if .LastError}}{{.LastError}} ({{.LastErrorTime.Format "2006-01-02 15:04:05"}}){{end}}</td>
+<td>
+<form method="post" style="display:inline"><input type="hidden" name="server" value="{{.Server}}">
+<button name="action" value="eject">eject</button>
+<button name="action" value="drain">drain</button>
+<button name="action" value="restore">restore</button>
+<input name="weight" size="3" value="{{.Weight}}"><button name="action" value="weight">weight</button>
+</form>
+</td>
+</tr>
+{{end}}
+</table>
+</body>
+</html>
+`))
@@ -0,0 +1,95 @@
+package gohttplb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestBearerTokenAuthorizer(t *testing.T) {
+	tests := []struct {
+		token  string
+		header string
+		want   bool
+	}{
+		{"secret", "Bearer secret", true},
+		{"secret", "secret", false},
+		{"secret", "Bearer other", false},
+		{"", "", false},
+		{"", "Bearer ", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/debug", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		if got := BearerTokenAuthorizer(tt.token)(req); got != tt.want {
+			t.Errorf("token %q, header %q: got %v, want %v", tt.token, tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestDebugHandlerRejectsCrossSitePost(t *testing.T) {
+	_, addr := newTestServer(t, okHandler)
+	lbc, err := New(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := lbc.DebugHandler(DebugConfig{Authorize: BasicAuthAuthorizer("admin", "secret")})
+
+	post := func(header, value string) int {
+		form := url.Values{"action": {"drain"}, "server": {addr}}
+		req := httptest.NewRequest(http.MethodPost, "http://example.com/debug", strings.NewReader(form.Encode()))
+		req.Header.Set(HeaderContentType, FormContentType)
+		req.SetBasicAuth("admin", "secret")
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := post("Origin", "http://evil.example"); code != http.StatusForbidden {
+		t.Fatalf("cross-site Origin: status %d, want 403", code)
+	}
+	if code := post("Sec-Fetch-Site", "cross-site"); code != http.StatusForbidden {
+		t.Fatalf("cross-site Sec-Fetch-Site: status %d, want 403", code)
+	}
+	if code := post("Origin", "http://example.com"); code != http.StatusSeeOther {
+		t.Fatalf("same origin: status %d, want 303", code)
+	}
+	if code := post("", ""); code != http.StatusSeeOther {
+		t.Fatalf("non-browser client: status %d, want 303", code)
+	}
+}
+
+func TestDebugHandlerAuthScheme(t *testing.T) {
+	_, addr := newTestServer(t, okHandler)
+	lbc, err := New(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		scheme string
+		want   string
+	}{
+		{"", `Basic realm="gohttplb"`},
+		{"Bearer", `Bearer realm="gohttplb"`},
+	}
+	for _, tt := range tests {
+		handler := lbc.DebugHandler(DebugConfig{Authorize: BearerTokenAuthorizer("secret"), AuthScheme: tt.scheme})
+		req := httptest.NewRequest(http.MethodPost, "/debug", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("scheme %q: status %d, want 401", tt.scheme, rec.Code)
+		}
+		if got := rec.Header().Get("WWW-Authenticate"); got != tt.want {
+			t.Fatalf("scheme %q: WWW-Authenticate %q, want %q", tt.scheme, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package gohttplb
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType is type of Event
+type EventType int
+
+// Event types
+const (
+	// EventServerEjected server ejected by Eject
+	EventServerEjected EventType = iota
+	// EventServerDraining server draining by Drain
+	EventServerDraining
+	// EventServerRestored server restored by Restore, or its ejection expired
+	EventServerRestored
+	// EventServerWeightChanged server weight changed by SetWeight
+	EventServerWeightChanged
+	// EventBreakerStateChanged server breaker changed state
+	EventBreakerStateChanged
+	// EventClusterBreakerStateChanged cluster breaker changed state
+	EventClusterBreakerStateChanged
+	// EventServerThrottled server asked to back off by Retry-After for Duration
+	EventServerThrottled
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventServerEjected:
+		return "server-ejected"
+	case EventServerDraining:
+		return "server-draining"
+	case EventServerRestored:
+		return "server-restored"
+	case EventServerWeightChanged:
+		return "server-weight-changed"
+	case EventBreakerStateChanged:
+		return "breaker-state-changed"
+	case EventClusterBreakerStateChanged:
+		return "cluster-breaker-state-changed"
+	case EventServerThrottled:
+		return "server-throttled"
+	}
+	return "unknown"
+}
+
+// Event is server state change sent to subscribers
+type Event struct {
+	Type EventType
+	// Server empty for cluster breaker events
+	Server string
+	Time   time.Time
+	// From and To breaker states of breaker events
+	From BreakerState
+	To   BreakerState
+	// Weight new weight of EventServerWeightChanged
+	Weight int
+	// Duration of EventServerEjected, 0 if ejected until restored, or delay of EventServerThrottled
+	Duration time.Duration
+}
+
+// eventHub send events to subscribers
+type eventHub struct {
+	mutex       sync.RWMutex
+	nextID      uint64
+	subscribers map[uint64]func(Event)
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subscribers: make(map[uint64]func(Event))}
+}
+
+func (h *eventHub) subscribe(fn func(Event)) (unsubscribe func()) {
+	h.mutex.Lock()
+	id := h.nextID
+	h.nextID++
+	h.subscribers[id] = fn
+	h.mutex.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			h.mutex.Lock()
+			delete(h.subscribers, id)
+			h.mutex.Unlock()
+		})
+	}
+}
+
+// publish call subscribers outside the lock so that they can unsubscribe
+func (h *eventHub) publish(event Event) {
+	if h == nil {
+		return
+	}
+	h.mutex.RLock()
+	if len(h.subscribers) == 0 {
+		h.mutex.RUnlock()
+		return
+	}
+	fns := make([]func(Event), 0, len(h.subscribers))
+	for _, fn := range h.subscribers {
+		fns = append(fns, fn)
+	}
+	h.mutex.RUnlock()
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	for _, fn := range fns {
+		fn(event)
+	}
+}
+
+// Subscribe call fn on every event until unsubscribe is called.
+// fn is called synchronously by the goroutine changing state, it must not block.
+func (lbc *LBClient) Subscribe(fn func(Event)) (unsubscribe func()) {
+	return lbc.R.events.subscribe(fn)
+}
+
+// SubscribeChan send every event to ch until unsubscribe is called,
+// events are dropped if ch is full. ch can be closed after unsubscribe.
+func (lbc *LBClient) SubscribeChan(ch chan<- Event) (unsubscribe func()) {
+	var (
+		mutex  sync.Mutex
+		closed bool
+	)
+	cancel := lbc.R.events.subscribe(func(event Event) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if closed {
+			return
+		}
+		select {
+		case ch <- event:
+		default:
+		}
+	})
+	return func() {
+		cancel()
+		mutex.Lock()
+		closed = true
+		mutex.Unlock()
+	}
+}
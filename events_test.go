@@ -0,0 +1,91 @@
+package gohttplb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetWeightSubscriberCanRequest(t *testing.T) {
+	_, addr := newTestServer(t, okHandler)
+	_, other := newTestServer(t, okHandler)
+	lbc, err := New(addr + "," + other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsubscribe := lbc.Subscribe(func(event Event) {
+		if event.Type != EventServerWeightChanged {
+			return
+		}
+		lbc.debugInfo()
+		resp, err := lbc.Get("/")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+	})
+	defer unsubscribe()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- lbc.SetWeight(addr, 3)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("SetWeight deadlocked with subscriber sending request")
+	}
+}
+
+func TestExpiredEjectionPublishesRestore(t *testing.T) {
+	_, addr := newTestServer(t, okHandler)
+	lbc, err := New(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan Event, 8)
+	unsubscribe := lbc.SubscribeChan(events)
+	defer unsubscribe()
+
+	if err = lbc.Eject(addr, 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	// no request picks the server, the restore is published when ejection expires
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case event := <-events:
+			if event.Type == EventServerRestored {
+				return
+			}
+		case <-timeout:
+			t.Fatal("no restore event after ejection expired")
+		}
+	}
+}
+
+func TestRestoreStopsEjectionTimer(t *testing.T) {
+	_, addr := newTestServer(t, okHandler)
+	lbc, err := New(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = lbc.Eject(addr, 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err = lbc.Restore(addr); err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan Event, 8)
+	unsubscribe := lbc.SubscribeChan(events)
+	defer unsubscribe()
+
+	select {
+	case event := <-events:
+		t.Fatalf("unexpected event %s after restore", event.Type)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
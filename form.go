@@ -0,0 +1,131 @@
+package gohttplb
+
+import (
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// Multipart is multipart/form-data request body, it is rebuilt for every attempt
+// and file parts are streamed from disk
+type Multipart struct {
+	boundary string
+	parts    []multipartPart
+}
+
+type multipartPart struct {
+	fieldName string
+	// fileName empty for field part
+	fileName string
+	value    string
+	open     func() (io.ReadCloser, error)
+}
+
+// NewMultipart new Multipart with random boundary
+func NewMultipart() *Multipart {
+	return &Multipart{boundary: multipart.NewWriter(ioutil.Discard).Boundary()}
+}
+
+// Field add field part
+func (m *Multipart) Field(fieldName, value string) *Multipart {
+	m.parts = append(m.parts, multipartPart{fieldName: fieldName, value: value})
+	return m
+}
+
+// File add file part read from filePath
+func (m *Multipart) File(fieldName, filePath string) *Multipart {
+	return m.FileReader(fieldName, filepath.Base(filePath), func() (io.ReadCloser, error) {
+		return os.Open(filePath)
+	})
+}
+
+// FileReader add file part, open is called every time the body is built
+func (m *Multipart) FileReader(fieldName, fileName string, open func() (io.ReadCloser, error)) *Multipart {
+	m.parts = append(m.parts, multipartPart{fieldName: fieldName, fileName: fileName, open: open})
+	return m
+}
+
+// ContentType return multipart/form-data Content-Type with boundary
+func (m *Multipart) ContentType() string {
+	return "multipart/form-data; boundary=" + m.boundary
+}
+
+// open return reader of body written by a goroutine
+func (m *Multipart) open() (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(m.write(pw))
+	}()
+	return pr, nil
+}
+
+func (m *Multipart) write(w io.Writer) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(m.boundary); err != nil {
+		return err
+	}
+	for _, part := range m.parts {
+		if part.open == nil {
+			if err := mw.WriteField(part.fieldName, part.value); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := m.writeFile(mw, part); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+func (m *Multipart) writeFile(mw *multipart.Writer, part multipartPart) error {
+	file, err := part.open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	pw, err := mw.CreateFormFile(part.fieldName, part.fileName)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(pw, file)
+	return err
+}
+
+// Form set url-encoded form body
+func (rb *RequestBuilder) Form(form url.Values) *RequestBuilder {
+	rb.headers.Set(HeaderContentType, FormContentType)
+	return rb.Body([]byte(form.Encode()))
+}
+
+// Multipart set multipart/form-data body
+func (rb *RequestBuilder) Multipart(m *Multipart) *RequestBuilder {
+	rb.headers.Set(HeaderContentType, m.ContentType())
+	return rb.BodyFunc(m.open, -1)
+}
+
+// PostForm post method request with url-encoded form body
+func (lbc *LBClient) PostForm(path string, form url.Values, paramsHeaders ...map[string]string) (resp *http.Response, err error) {
+	params, headers := lbc.formParamsHeaders(paramsHeaders)
+	return lbc.R.post(http.MethodPost, path, params, headers, []byte(form.Encode()))
+}
+
+// PutForm put method request with url-encoded form body
+func (lbc *LBClient) PutForm(path string, form url.Values, paramsHeaders ...map[string]string) (resp *http.Response, err error) {
+	params, headers := lbc.formParamsHeaders(paramsHeaders)
+	return lbc.R.put(http.MethodPut, path, params, headers, []byte(form.Encode()))
+}
+
+func (lbc *LBClient) formParamsHeaders(paramsHeaders []map[string]string) (params map[string]string, headers map[string]string) {
+	params, headers = lbc.parseParamsHeaders(paramsHeaders)
+	if headers == nil {
+		headers = make(map[string]string)
+	}
+	headers[HeaderContentType] = FormContentType
+	return
+}
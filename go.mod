@@ -1,3 +1,3 @@
 module github.com/beeeeeeenny/gohttplb
 
-go 1.12
+go 1.21
@@ -0,0 +1,267 @@
+package gohttplb
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Default hedge config
+var (
+	DefaultHedgePercentile = 0.95
+	DefaultHedgeDelay      = 100 * time.Millisecond
+	DefaultHedgeMaxHedges  = 1
+)
+
+const (
+	// latencyWindowSize number of recent latencies kept for percentile
+	latencyWindowSize = 256
+	// hedgeMinSamples samples required before percentile delay is used
+	hedgeMinSamples = 20
+)
+
+// HedgeConfig for hedged requests, only idempotent requests with replayable body are hedged:
+// GET, HEAD, OPTIONS, TRACE, PUT, DELETE or request with Idempotency-Key header.
+// If the first server has not responded within delay, the same request is sent
+// to another server, the first response without error or retry status wins and the rest are canceled.
+// Failed attempts are retried like unhedged requests, a server is not hedged while it has an attempt in flight.
+type HedgeConfig struct {
+	// Delay fixed delay before sending a hedged request
+	// Default 100ms, used until enough samples if Percentile set
+	Delay time.Duration
+	// Percentile use latency percentile of recent requests as delay, range (0, 1)
+	// Default 0.95 if Delay not set either
+	Percentile float64
+	// MaxHedges max hedged requests sent in addition to the first one
+	// Default 1
+	MaxHedges int
+}
+
+func setDefaultHedgeConf(conf *HedgeConfig) {
+	if conf.Delay == 0 && conf.Percentile == 0 {
+		conf.Percentile = DefaultHedgePercentile
+	}
+	if conf.Delay == 0 {
+		conf.Delay = DefaultHedgeDelay
+	}
+	if conf.MaxHedges == 0 {
+		conf.MaxHedges = DefaultHedgeMaxHedges
+	}
+}
+
+func (rA *rArgs) idempotent() bool {
+	switch rA.method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return rA.headers.Get("Idempotency-Key") != ""
+
+}
+
+func (r *R) hedgeDelay() time.Duration {
+	if r.Hedge.Percentile > 0 {
+		if delay, ok := r.latency.percentile(r.Hedge.Percentile, hedgeMinSamples); ok {
+			return delay
+		}
+	}
+	return r.Hedge.Delay
+}
+
+type hedgeResult struct {
+	// id index of attempt cancel in doHedged
+	id     int
+	server string
+	resp   *http.Response
+	err    error
+	cancel context.CancelFunc
+	// throttled response throttled its server
+	throttled bool
+}
+
+func (result hedgeResult) success(rA *rArgs) bool {
+	return result.err == nil && !rA.retryStatusCode(result.resp) && !result.throttled
+}
+
+// doHedged send request to one server and hedge to others after delay,
+// failed attempts are retried immediately while retries remain.
+// Returned error is the reason of failure like R.tryServers
+func (r *R) doHedged(rA *rArgs, clusterGeneration uint64) (resp *http.Response, err error) {
+	maxAttempts := rA.retry * len(r.servers)
+	results := make(chan hedgeResult, maxAttempts)
+	// busy servers with an attempt in flight are not picked, they can be retried once it finished
+	busy := make(map[string]bool, len(r.servers))
+	attempts, hedges, inflight := 0, 0, 0
+	// cancels of all launched attempts, losers are canceled once a winner is chosen
+	cancels := make([]context.CancelFunc, 0, maxAttempts)
+
+	launch := func() bool {
+		if attempts > 0 {
+			if retryErr := r.canRetry(rA); retryErr != nil {
+				err = retryErr
+				return false
+			}
+		}
+		n, generation, pickErr := r.pick(rA.ctx, busy)
+		if pickErr != nil {
+			if attempts == 0 || pickErr != ErrNoHealthyServers {
+				err = pickErr
+			}
+			return false
+		}
+		busy[n.server] = true
+		attempts++
+		inflight++
+
+		ctx, cancel := context.WithCancel(rA.ctx)
+		id := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, err := r.send(ctx, rA, n, generation, clusterGeneration)
+			results <- hedgeResult{id: id, server: n.server, resp: resp, err: err, cancel: cancel,
+				throttled: r.throttledBy(n, resp)}
+		}()
+		return true
+	}
+
+	if !launch() {
+		r.cancelBegin(clusterGeneration)
+		if err == nil {
+			err = ErrNoHealthyServers
+		}
+		return nil, err
+	}
+
+	timer := time.NewTimer(r.hedgeDelay())
+	defer timer.Stop()
+
+	var last *hedgeResult
+	for inflight > 0 {
+		select {
+		case <-timer.C:
+			if hedges < r.Hedge.MaxHedges && attempts < maxAttempts && launch() {
+				r.Logger.Debug("gohttplb hedged request sent", "method", rA.method, "path", rA.path)
+				hedges++
+				timer.Reset(r.hedgeDelay())
+			}
+		case result := <-results:
+			inflight--
+			delete(busy, result.server)
+			if result.success(rA) {
+				if last != nil {
+					last.close()
+				}
+				for id, cancel := range cancels {
+					if id != result.id {
+						cancel()
+					}
+				}
+				go drainHedged(results, inflight)
+				return withCancelBody(result.resp, result.cancel), nil
+			}
+			if last != nil {
+				last.close()
+			}
+			last = &result
+			if inflight == 0 && attempts < maxAttempts {
+				launch()
+			}
+		}
+	}
+
+	if last == nil {
+		return nil, err
+	}
+	if err != nil {
+		last.close()
+		return nil, err
+	}
+	if last.err != nil {
+		last.cancel()
+		return nil, ErrAllServersFailed
+	}
+	if last.throttled {
+		if throttledErr := r.throttledError(); throttledErr != nil {
+			last.close()
+			return nil, throttledErr
+		}
+	}
+	return withCancelBody(last.resp, last.cancel), nil
+}
+
+// close release result not returned to caller
+func (result *hedgeResult) close() {
+	if result.resp != nil {
+		result.resp.Body.Close()
+	}
+	result.cancel()
+}
+
+// drainHedged close losing hedged requests canceled by doHedged
+func drainHedged(results chan hedgeResult, inflight int) {
+	for ; inflight > 0; inflight-- {
+		result := <-results
+		result.close()
+	}
+}
+
+// cancelBody cancel request context when response body closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (body *cancelBody) Close() error {
+	err := body.ReadCloser.Close()
+	body.cancel()
+	return err
+}
+
+func withCancelBody(resp *http.Response, cancel context.CancelFunc) *http.Response {
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp
+}
+
+// latencyWindow keeps recent request latencies
+type latencyWindow struct {
+	mutex   sync.Mutex
+	samples []time.Duration
+	next    int
+	full    bool
+}
+
+func newLatencyWindow(size int) *latencyWindow {
+	return &latencyWindow{samples: make([]time.Duration, size)}
+}
+
+func (w *latencyWindow) add(latency time.Duration) {
+	w.mutex.Lock()
+	w.samples[w.next] = latency
+	w.next = (w.next + 1) % len(w.samples)
+	if w.next == 0 {
+		w.full = true
+	}
+	w.mutex.Unlock()
+}
+
+// percentile return p latency of samples, ok false if less than minSamples
+func (w *latencyWindow) percentile(p float64, minSamples int) (latency time.Duration, ok bool) {
+	w.mutex.Lock()
+	n := w.next
+	if w.full {
+		n = len(w.samples)
+	}
+	if n < minSamples || n == 0 {
+		w.mutex.Unlock()
+		return 0, false
+	}
+	sorted := make([]time.Duration, n)
+	copy(sorted, w.samples[:n])
+	w.mutex.Unlock()
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[int(p*float64(n-1))], true
+}
@@ -0,0 +1,138 @@
+package gohttplb
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newHedgeTestServers start two servers, the first request received is slow until canceled
+func newHedgeTestServers(t *testing.T) (addrs string, canceled chan struct{}) {
+	t.Helper()
+	var requests int32
+	canceled = make(chan struct{})
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			select {
+			case <-time.After(2 * time.Second):
+			case <-req.Context().Done():
+				close(canceled)
+			}
+			return
+		}
+		w.Write([]byte("ok"))
+	}
+	_, addr1 := newTestServer(t, handler)
+	_, addr2 := newTestServer(t, handler)
+	return addr1 + "," + addr2, canceled
+}
+
+func TestHedgeCancelsLoser(t *testing.T) {
+	addrs, canceled := newHedgeTestServers(t)
+	lbc, err := New(addrs, &LBConfig{Hedge: &HedgeConfig{Delay: 20 * time.Millisecond}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := lbc.Get("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	select {
+	case <-canceled:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("slow hedged request not canceled after winner returned")
+	}
+}
+
+func TestHedgeCanceledLoserIsNotFailure(t *testing.T) {
+	addrs, canceled := newHedgeTestServers(t)
+	lbc, err := New(addrs, &LBConfig{
+		Hedge:          &HedgeConfig{Delay: 20 * time.Millisecond},
+		Breaker:        &BreakerConfig{ConsecutiveFailures: 1},
+		ClusterBreaker: &BreakerConfig{ConsecutiveFailures: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := lbc.Get("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	<-canceled
+
+	// loser is recorded shortly after it is canceled
+	for deadline := time.Now().Add(100 * time.Millisecond); time.Now().Before(deadline); {
+		for _, n := range lbc.R.nodes {
+			if state := n.breaker.State(); state != BreakerClosed {
+				t.Fatalf("server %s breaker state = %s, want closed", n.server, state)
+			}
+		}
+		if state := lbc.R.cluster.State(); state != BreakerClosed {
+			t.Fatalf("cluster breaker state = %s, want closed", state)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// closedAddr return address refusing connections
+func closedAddr(t *testing.T) string {
+	t.Helper()
+	server, addr := newTestServer(t, okHandler)
+	server.Close()
+	return addr
+}
+
+func TestHedgeRetriesSameServer(t *testing.T) {
+	lbc, err := New(closedAddr(t), &LBConfig{
+		Retry: 3,
+		Hedge: &HedgeConfig{Delay: time.Second},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = lbc.Get("/")
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) {
+		t.Fatalf("err = %v, want RequestError", err)
+	}
+	if len(reqErr.Attempts) != 3 {
+		t.Fatalf("attempts = %d, want 3", len(reqErr.Attempts))
+	}
+}
+
+func TestHedgeReturnsStatusNotRetried(t *testing.T) {
+	var requests int32
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	_, addr := newTestServer(t, handler)
+	_, other := newTestServer(t, handler)
+	lbc, err := New(addr+","+other, &LBConfig{Hedge: &HedgeConfig{Delay: time.Second}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := lbc.Get("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", resp.StatusCode)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Fatalf("requests = %d, want 1", n)
+	}
+}
@@ -0,0 +1,101 @@
+package gohttplb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// HTTPError is returned by JSON helpers if response status code is not 2xx
+type HTTPError struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("StatusCode not ok: %d-%s", e.StatusCode, e.Body)
+}
+
+// DecodeErrorBody decode json body of *HTTPError in err chain into E, e.g.
+//
+//	if apiErr, ok := gohttplb.DecodeErrorBody[APIError](err); ok {
+//		log.Println(apiErr.Message)
+//	}
+func DecodeErrorBody[E any](err error) (body E, ok bool) {
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		return body, false
+	}
+	if json.Unmarshal(httpErr.Body, &body) != nil {
+		return body, false
+	}
+	return body, true
+}
+
+// DoJSON encode req as json body, send request built by rb and decode 2xx response into Resp.
+// Request has no body if req is nil interface, non 2xx response returns *HTTPError.
+func DoJSON[Req, Resp any](ctx context.Context, rb *RequestBuilder, req Req) (result Resp, err error) {
+	resp, err := doJSON(ctx, rb, req)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return result, newHTTPError(resp)
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err == io.EOF {
+		err = nil
+	}
+	return result, err
+}
+
+// GetJSON send request without body and decode 2xx response into Resp
+func GetJSON[Resp any](ctx context.Context, rb *RequestBuilder) (Resp, error) {
+	return DoJSON[interface{}, Resp](ctx, rb, nil)
+}
+
+// DoJSONData like DoJSON, but parse response with LBConfig.ResponseParser and
+// decode parsed data, e.g. data field of DefaultResponseParser envelope, into Resp
+func DoJSONData[Req, Resp any](ctx context.Context, rb *RequestBuilder, req Req) (result Resp, err error) {
+	resp, err := doJSON(ctx, rb, req)
+	if err != nil {
+		return result, err
+	}
+	_, data, err := rb.r.parseResponse(resp)
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
+
+// GetJSONData like GetJSON, but decode parsed data into Resp
+func GetJSONData[Resp any](ctx context.Context, rb *RequestBuilder) (Resp, error) {
+	return DoJSONData[interface{}, Resp](ctx, rb, nil)
+}
+
+func doJSON[Req any](ctx context.Context, rb *RequestBuilder, req Req) (*http.Response, error) {
+	if interface{}(req) != nil {
+		body, err := json.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+		rb.Body(body)
+	}
+	return rb.JSON().Do(ctx)
+}
+
+func newHTTPError(resp *http.Response) *HTTPError {
+	body, _ := ioutil.ReadAll(resp.Body)
+	return &HTTPError{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+	}
+}
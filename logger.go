@@ -0,0 +1,28 @@
+package gohttplb
+
+import "log/slog"
+
+// Logger is structured logger, keysAndValues are alternating keys and values
+// like log/slog, *slog.Logger implements it
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+}
+
+// NewSlogLogger adapt *slog.Logger to Logger, slog.Default() is used if logger is nil
+func NewSlogLogger(logger *slog.Logger) Logger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return logger
+}
+
+// noopLogger discard all logs
+type noopLogger struct{}
+
+func (noopLogger) Debug(msg string, keysAndValues ...interface{}) {}
+func (noopLogger) Info(msg string, keysAndValues ...interface{})  {}
+func (noopLogger) Warn(msg string, keysAndValues ...interface{})  {}
+func (noopLogger) Error(msg string, keysAndValues ...interface{}) {}
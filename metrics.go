@@ -0,0 +1,243 @@
+package gohttplb
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMetricsBuckets latency histogram buckets in seconds
+var DefaultMetricsBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// metrics collect LBClient metrics rendered in Prometheus text format
+type metrics struct {
+	mutex sync.Mutex
+	// requests logical requests by method, status
+	requests map[[2]string]uint64
+	// attempts by server, method, status
+	attempts map[[3]string]uint64
+	// durations attempt latency by server
+	durations map[string]*histogram
+	retries   uint64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		requests:  make(map[[2]string]uint64),
+		attempts:  make(map[[3]string]uint64),
+		durations: make(map[string]*histogram),
+	}
+}
+
+func statusLabel(resp *http.Response, err error) string {
+	if err != nil || resp == nil {
+		return "error"
+	}
+	return strconv.Itoa(resp.StatusCode)
+}
+
+func (m *metrics) addRequest(method string, resp *http.Response, err error) {
+	m.mutex.Lock()
+	m.requests[[2]string{method, statusLabel(resp, err)}]++
+	m.mutex.Unlock()
+}
+
+func (m *metrics) addAttempt(server, method string, resp *http.Response, err error, duration time.Duration) {
+	m.mutex.Lock()
+	m.attempts[[3]string{server, method, statusLabel(resp, err)}]++
+	h, ok := m.durations[server]
+	if !ok {
+		h = newHistogram(DefaultMetricsBuckets)
+		m.durations[server] = h
+	}
+	h.observe(duration.Seconds())
+	m.mutex.Unlock()
+}
+
+func (m *metrics) addRetry() {
+	atomic.AddUint64(&m.retries, 1)
+}
+
+// histogram is cumulative histogram, not safe for concurrent use
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, bucket := range h.buckets {
+		if v <= bucket {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// MetricsHandler return http.Handler rendering metrics in Prometheus text exposition format
+func (lbc *LBClient) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set(HeaderContentType, "text/plain; version=0.0.4; charset=utf-8")
+		bw := bufio.NewWriter(w)
+		lbc.R.writeMetrics(bw)
+		bw.Flush()
+	})
+}
+
+func (r *R) writeMetrics(w *bufio.Writer) {
+	m := r.metrics
+	m.mutex.Lock()
+	writeHeader(w, "gohttplb_requests_total", "counter", "Requests by method and final status.")
+	for _, key := range sortedKeys2(m.requests) {
+		writeSample(w, "gohttplb_requests_total", labels("method", key[0], "status", key[1]), float64(m.requests[key]))
+	}
+
+	writeHeader(w, "gohttplb_attempts_total", "counter", "Attempts by server, method and status.")
+	for _, key := range sortedKeys3(m.attempts) {
+		writeSample(w, "gohttplb_attempts_total",
+			labels("server", key[0], "method", key[1], "status", key[2]), float64(m.attempts[key]))
+	}
+
+	writeHeader(w, "gohttplb_attempt_duration_seconds", "histogram", "Attempt latency by server.")
+	servers := make([]string, 0, len(m.durations))
+	for server := range m.durations {
+		servers = append(servers, server)
+	}
+	sort.Strings(servers)
+	for _, server := range servers {
+		h := m.durations[server]
+		for i, bucket := range h.buckets {
+			writeSample(w, "gohttplb_attempt_duration_seconds_bucket",
+				labels("server", server, "le", formatFloat(bucket)), float64(h.counts[i]))
+		}
+		writeSample(w, "gohttplb_attempt_duration_seconds_bucket",
+			labels("server", server, "le", "+Inf"), float64(h.count))
+		writeSample(w, "gohttplb_attempt_duration_seconds_sum", labels("server", server), h.sum)
+		writeSample(w, "gohttplb_attempt_duration_seconds_count", labels("server", server), float64(h.count))
+	}
+	m.mutex.Unlock()
+
+	writeHeader(w, "gohttplb_retries_total", "counter", "Retries sent, including hedged requests.")
+	writeSample(w, "gohttplb_retries_total", "", float64(atomic.LoadUint64(&m.retries)))
+	if r.budget != nil {
+		writeHeader(w, "gohttplb_retry_budget_denied_total", "counter", "Retries denied by retry budget.")
+		writeSample(w, "gohttplb_retry_budget_denied_total", "", float64(r.budget.stats().Denied))
+	}
+
+	writeHeader(w, "gohttplb_in_flight_requests", "gauge", "Requests in flight by server.")
+	for _, server := range r.servers {
+		writeSample(w, "gohttplb_in_flight_requests", labels("server", server), float64(r.nodes[server].inFlightCount()))
+	}
+
+	writeHeader(w, "gohttplb_server_in_rotation", "gauge", "Server in rotation, 0 if ejected or draining.")
+	for _, server := range r.servers {
+		writeSample(w, "gohttplb_server_in_rotation", labels("server", server), boolFloat(r.nodes[server].inRotation()))
+	}
+
+	if r.Bulkhead != nil && r.Bulkhead.Adaptive != nil {
+		writeHeader(w, "gohttplb_concurrency_limit", "gauge", "Adaptive concurrency limit by server.")
+		for _, server := range r.servers {
+			writeSample(w, "gohttplb_concurrency_limit", labels("server", server), float64(r.nodes[server].adaptive.getLimit()))
+		}
+	}
+
+	if r.Breaker != nil {
+		writeHeader(w, "gohttplb_breaker_state", "gauge", "Breaker state by server, 0 closed, 1 open, 2 half-open.")
+		for _, server := range r.servers {
+			writeSample(w, "gohttplb_breaker_state", labels("server", server), float64(r.nodes[server].breaker.State()))
+		}
+	}
+	if r.cluster != nil {
+		writeHeader(w, "gohttplb_cluster_breaker_state", "gauge", "Cluster breaker state, 0 closed, 1 open, 2 half-open.")
+		writeSample(w, "gohttplb_cluster_breaker_state", "", float64(r.cluster.State()))
+	}
+}
+
+func writeHeader(w *bufio.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func writeSample(w *bufio.Writer, name, labels string, value float64) {
+	fmt.Fprintf(w, "%s%s %s\n", name, labels, formatFloat(value))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labels format label pairs as {k1="v1",k2="v2"}
+func labels(pairs ...string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(pairs[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func boolFloat(v bool) float64 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys2(m map[[2]string]uint64) [][2]string {
+	keys := make([][2]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i][0] < keys[j][0] || keys[i][0] == keys[j][0] && keys[i][1] < keys[j][1]
+	})
+	return keys
+}
+
+func sortedKeys3(m map[[3]string]uint64) [][3]string {
+	keys := make([][3]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		if a[1] != b[1] {
+			return a[1] < b[1]
+		}
+		return a[2] < b[2]
+	})
+	return keys
+}
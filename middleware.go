@@ -0,0 +1,121 @@
+package gohttplb
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+)
+
+// Handler send http request
+type Handler func(req *http.Request) (*http.Response, error)
+
+// Middleware wrap Handler to add behavior around it, e.g. auth, logging, metrics, signing
+type Middleware func(next Handler) Handler
+
+// chain wrap handler with middlewares, first middleware is outermost
+func chain(middlewares []Middleware, handler Handler) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// AttemptInfo is attempt info in request context of LBConfig.Middlewares
+type AttemptInfo struct {
+	// Server chosen by scheduler
+	Server string
+	// Attempt number of this request, start from 1
+	Attempt int
+}
+
+type attemptInfoKey struct{}
+
+// AttemptFromContext return AttemptInfo of request context in LBConfig.Middlewares
+func AttemptFromContext(ctx context.Context) (info AttemptInfo, ok bool) {
+	info, ok = ctx.Value(attemptInfoKey{}).(AttemptInfo)
+	return
+}
+
+// attemptContext add AttemptInfo of next attempt to ctx
+func (rA *rArgs) attemptContext(ctx context.Context, server string) context.Context {
+	return context.WithValue(ctx, attemptInfoKey{}, AttemptInfo{
+		Server:  server,
+		Attempt: int(atomic.AddInt32(&rA.attemptNumber, 1)),
+	})
+}
+
+// doCall run LBConfig.CallMiddlewares once around retries, request passed to them has
+// no scheme, host and body. Method, URL path, query and header changed by them are used.
+func (r *R) doCall(rA *rArgs) (resp *http.Response, err error) {
+	if r.Tracer != nil {
+		var span Span
+		rA.ctx, span = r.startCallSpan(rA)
+		defer func() {
+			endCallSpan(span, resp, err)
+		}()
+	}
+	if len(r.CallMiddlewares) == 0 {
+		return r.doRetry(rA)
+	}
+
+	u, err := url.Parse(rA.path)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	for key, vals := range rA.params {
+		q[key] = append(q[key], vals...)
+	}
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(rA.ctx, rA.method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = rA.headers
+
+	handler := chain(r.CallMiddlewares, func(req *http.Request) (*http.Response, error) {
+		rA.ctx = req.Context()
+		rA.method = req.Method
+		rA.path = req.URL.RequestURI()
+		rA.params = make(url.Values)
+		rA.headers = req.Header
+		return r.doRetry(rA)
+	})
+	return handler(req)
+}
+
+// HeaderMiddleware set header for every attempt
+func HeaderMiddleware(key, value string) Middleware {
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			req.Header.Set(key, value)
+			return next(req)
+		}
+	}
+}
+
+// BasicAuthMiddleware set basic auth for every attempt
+func BasicAuthMiddleware(username, password string) Middleware {
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			req.SetBasicAuth(username, password)
+			return next(req)
+		}
+	}
+}
+
+// BearerTokenMiddleware set Authorization header with token returned by tokenFunc,
+// tokenFunc is called for every request so that token can be refreshed
+func BearerTokenMiddleware(tokenFunc func(ctx context.Context) (string, error)) Middleware {
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			token, err := tokenFunc(req.Context())
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			return next(req)
+		}
+	}
+}
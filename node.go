@@ -0,0 +1,244 @@
+package gohttplb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ewmaAlpha weight of latest latency in EWMA latency
+const ewmaAlpha = 0.2
+
+// node is a request server with its runtime state
+type node struct {
+	server  string
+	weight  int
+	breaker *circuitBreaker
+	limiter *rateLimiter
+	// adaptive concurrency limit of bulkhead
+	adaptive *adaptiveLimiter
+	events   *eventHub
+	// inFlight requests sent and waiting for response
+	inFlight int64
+	// active requests holding bulkhead slot of node
+	active   int64
+	requests uint64
+	errors   uint64
+
+	mutex         sync.Mutex
+	ewmaLatency   time.Duration
+	lastError     error
+	lastErrorTime time.Time
+	// ejected servers are skipped until ejectedUntil, or restored if zero
+	ejected      bool
+	ejectedUntil time.Time
+	// ejectTimer restore node when ejection expires even if node is not picked
+	ejectTimer *time.Timer
+	// draining servers take no new requests until restored
+	draining bool
+	// throttledUntil server asked to back off by Retry-After until it
+	throttledUntil time.Time
+}
+
+func newNode(server string, conf *LBConfig, events *eventHub) *node {
+	n := &node{server: server, weight: 1, events: events}
+	if conf.Breaker != nil {
+		n.breaker = newCircuitBreaker(server, conf.Breaker, conf.Logger, events)
+	}
+	if conf.Bulkhead != nil && conf.Bulkhead.Adaptive != nil {
+		n.adaptive = newAdaptiveLimiter(conf.Bulkhead.Adaptive)
+	}
+	return n
+}
+
+// allow reports whether node can take a request now
+func (n *node) allow() (generation uint64, ok bool) {
+	if !n.inRotation() {
+		return 0, false
+	}
+	if n.breaker == nil {
+		return 0, true
+	}
+	return n.breaker.allow()
+}
+
+// done records request result on node
+func (n *node) done(generation uint64, resp *http.Response, err error, latency time.Duration) {
+	atomic.AddUint64(&n.requests, 1)
+	// canceled requests like hedged losers tell nothing about server health or capacity
+	if canceled(err) {
+		if n.breaker != nil {
+			n.breaker.cancel(generation)
+		}
+		return
+	}
+
+	failure := n.isFailure(resp, err)
+	if failure {
+		atomic.AddUint64(&n.errors, 1)
+	}
+
+	n.mutex.Lock()
+	if n.ewmaLatency == 0 {
+		n.ewmaLatency = latency
+	} else {
+		n.ewmaLatency = time.Duration(ewmaAlpha*float64(latency) + (1-ewmaAlpha)*float64(n.ewmaLatency))
+	}
+	if failure {
+		n.lastError = err
+		if err == nil {
+			n.lastError = fmt.Errorf("StatusCode not ok: %d", resp.StatusCode)
+		}
+		n.lastErrorTime = time.Now()
+	}
+	n.mutex.Unlock()
+
+	if n.breaker != nil {
+		n.breaker.done(generation, failure)
+	}
+	if n.adaptive != nil {
+		n.adaptive.sample(latency, atomic.LoadInt64(&n.active), failure)
+	}
+}
+
+// canceled reports whether request was canceled by caller or by hedging
+func canceled(err error) bool {
+	return err != nil && errors.Is(err, context.Canceled)
+}
+
+func (n *node) isFailure(resp *http.Response, err error) bool {
+	if n.breaker != nil {
+		return n.breaker.conf.IsFailure(resp, err)
+	}
+	return defaultIsFailure(resp, err)
+}
+
+// inRotation reports whether node is not ejected or draining
+func (n *node) inRotation() bool {
+	n.mutex.Lock()
+	expired := n.ejected && !n.ejectedUntil.IsZero() && !time.Now().Before(n.ejectedUntil)
+	if expired {
+		n.ejected = false
+	}
+	ok := !n.ejected && !n.draining
+	n.mutex.Unlock()
+	if expired {
+		n.events.publish(Event{Type: EventServerRestored, Server: n.server})
+	}
+	return ok
+}
+
+// eject take node out of rotation for duration, until restored if duration is 0
+func (n *node) eject(duration time.Duration) {
+	n.mutex.Lock()
+	n.stopEjectTimer()
+	n.ejected = true
+	n.ejectedUntil = time.Time{}
+	if duration > 0 {
+		n.ejectedUntil = time.Now().Add(duration)
+		n.ejectTimer = time.AfterFunc(duration, func() { n.inRotation() })
+	}
+	n.mutex.Unlock()
+}
+
+// stopEjectTimer stop timer of previous ejection, called with mutex locked
+func (n *node) stopEjectTimer() {
+	if n.ejectTimer != nil {
+		n.ejectTimer.Stop()
+		n.ejectTimer = nil
+	}
+}
+
+// drain stop sending new requests to node
+func (n *node) drain() {
+	n.mutex.Lock()
+	n.draining = true
+	n.mutex.Unlock()
+}
+
+// restore put ejected or draining node back to rotation
+func (n *node) restore() {
+	n.mutex.Lock()
+	n.stopEjectTimer()
+	n.ejected = false
+	n.ejectedUntil = time.Time{}
+	n.draining = false
+	n.mutex.Unlock()
+}
+
+// throttle skip node until t
+func (n *node) throttle(t time.Time) {
+	n.mutex.Lock()
+	if t.After(n.throttledUntil) {
+		n.throttledUntil = t
+	}
+	n.mutex.Unlock()
+}
+
+// throttledFor return remaining throttle delay, 0 if not throttled
+func (n *node) throttledFor(now time.Time) time.Duration {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if d := n.throttledUntil.Sub(now); d > 0 {
+		return d
+	}
+	return 0
+}
+
+func (n *node) setWeight(weight int) {
+	n.mutex.Lock()
+	n.weight = weight
+	n.mutex.Unlock()
+}
+
+func (n *node) getWeight() int {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.weight
+}
+
+func (n *node) start() {
+	atomic.AddInt64(&n.inFlight, 1)
+}
+
+func (n *node) finish() {
+	atomic.AddInt64(&n.inFlight, -1)
+}
+
+func (n *node) inFlightCount() int64 {
+	return atomic.LoadInt64(&n.inFlight)
+}
+
+// stats return snapshot of node
+func (n *node) stats() ServerStats {
+	stats := ServerStats{
+		Server:   n.server,
+		Requests: atomic.LoadUint64(&n.requests),
+		Errors:   atomic.LoadUint64(&n.errors),
+		InFlight: n.inFlightCount(),
+		State:    BreakerClosed,
+	}
+	n.inRotation()
+	n.mutex.Lock()
+	stats.Weight = n.weight
+	stats.Ejected = n.ejected
+	stats.Draining = n.draining
+	if time.Now().Before(n.throttledUntil) {
+		stats.ThrottledUntil = n.throttledUntil
+	}
+	stats.EWMALatency = n.ewmaLatency
+	stats.LastError = n.lastError
+	stats.LastErrorTime = n.lastErrorTime
+	n.mutex.Unlock()
+	if n.breaker != nil {
+		stats.State = n.breaker.State()
+	}
+	if n.adaptive != nil {
+		stats.ConcurrencyLimit = n.adaptive.getLimit()
+	}
+	return stats
+}
@@ -2,17 +2,21 @@ package gohttplb
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 )
 
-// ResponseParser custom response parser
+// ResponseParser custom response parser, Parse is called concurrently by requests
+// so it must not keep per response state in parser
 type ResponseParser interface {
 	Parse(*http.Response) (int, []byte, error)
 }
 
-// DefaultResponseParser for default data struct
+// DefaultResponseParser for default data struct, response is decoded into a new value
+// so parser is not modified by Parse and safe for concurrent use
 type DefaultResponseParser struct {
 	Success bool        `json:"success"`
 	Code    int         `json:"code"`
@@ -24,29 +28,143 @@ type DefaultResponseParser struct {
 func (parser *DefaultResponseParser) Parse(resp *http.Response) (statusCode int, data []byte, err error) {
 	defer resp.Body.Close()
 	statusCode = resp.StatusCode
+	if err = decodeResponse(resp); err != nil {
+		return
+	}
 	bodyBytes, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return
 	}
 
-	if err = json.Unmarshal(bodyBytes, parser); err != nil {
+	var envelope DefaultResponseParser
+	if err = json.Unmarshal(bodyBytes, &envelope); err != nil {
 		return
 	}
 
-	data, err = json.Marshal(parser.Data)
+	data, err = json.Marshal(envelope.Data)
 	if err != nil {
 		return
 	}
 
-	if !parser.Success {
-		err = fmt.Errorf("Unsuccess: %d-%s", parser.Code, parser.Message)
+	if !envelope.Success {
+		err = fmt.Errorf("Unsuccess: %d-%s", envelope.Code, envelope.Message)
 		return
 	}
 
 	if statusCode < http.StatusOK || statusCode >= http.StatusMultipleChoices {
-		err = fmt.Errorf("StatusCode not ok: %d-%s", parser.Code, parser.Message)
+		err = fmt.Errorf("StatusCode not ok: %d-%s", envelope.Code, envelope.Message)
 		return
 	}
 
 	return
 }
+
+// DefaultEnvelopeParser parse the same envelope as DefaultResponseParser
+var DefaultEnvelopeParser = &EnvelopeParser{
+	SuccessPath: "success",
+	CodePath:    "code",
+	MessagePath: "message",
+	DataPath:    "data",
+}
+
+// EnvelopeParser parse JSON envelope with configurable field paths, nested fields are
+// separated by ".", e.g. "result.data". It is stateless and safe for concurrent use.
+//
+// Envelope with `code == 0` as success:
+//
+//	&gohttplb.EnvelopeParser{CodePath: "code", MessagePath: "msg", DataPath: "result.data"}
+type EnvelopeParser struct {
+	// SuccessPath path of boolean success flag
+	// If empty, success is decided by SuccessCode
+	SuccessPath string
+	// CodePath path of business code, number or string
+	CodePath string
+	// SuccessCode code of success if SuccessPath is empty, compared with code at CodePath as text
+	// Default "0", responses are not checked if CodePath is empty too
+	SuccessCode string
+	// MessagePath path of error message
+	MessagePath string
+	// DataPath path of payload returned as data
+	// Default whole body
+	DataPath string
+}
+
+// Parse implemente interface `ResponseParser.Parse`
+func (parser *EnvelopeParser) Parse(resp *http.Response) (statusCode int, data []byte, err error) {
+	defer resp.Body.Close()
+	statusCode = resp.StatusCode
+	if err = decodeResponse(resp); err != nil {
+		return
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	if !json.Valid(body) {
+		return statusCode, nil, errors.New("invalid json envelope")
+	}
+
+	data = body
+	if parser.DataPath != "" {
+		if data = jsonPath(body, parser.DataPath); data == nil {
+			data = []byte("null")
+		}
+	}
+	code := jsonPathText(body, parser.CodePath)
+	message := jsonPathText(body, parser.MessagePath)
+
+	success := true
+	switch {
+	case parser.SuccessPath != "":
+		success = string(jsonPath(body, parser.SuccessPath)) == "true"
+	case parser.CodePath != "":
+		successCode := parser.SuccessCode
+		if successCode == "" {
+			successCode = "0"
+		}
+		success = code == successCode
+	}
+	if !success {
+		err = fmt.Errorf("Unsuccess: %s-%s", code, message)
+		return
+	}
+
+	if statusCode < http.StatusOK || statusCode >= http.StatusMultipleChoices {
+		err = fmt.Errorf("StatusCode not ok: %s-%s", code, message)
+		return
+	}
+
+	return
+}
+
+// jsonPath return raw value at path of JSON document, nil if not found
+func jsonPath(doc []byte, path string) []byte {
+	raw := json.RawMessage(doc)
+	for _, key := range strings.Split(path, ".") {
+		var object map[string]json.RawMessage
+		if json.Unmarshal(raw, &object) != nil {
+			return nil
+		}
+		var ok bool
+		if raw, ok = object[key]; !ok {
+			return nil
+		}
+	}
+	return raw
+}
+
+// jsonPathText return value at path as text, strings are unquoted, "" if path is empty or not found
+func jsonPathText(doc []byte, path string) string {
+	if path == "" {
+		return ""
+	}
+	raw := jsonPath(doc, path)
+	if raw == nil || string(raw) == "null" {
+		return ""
+	}
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return text
+	}
+	return string(raw)
+}
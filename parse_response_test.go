@@ -0,0 +1,105 @@
+package gohttplb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentParse(t *testing.T) {
+	_, addr := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		v := req.URL.Query().Get("v")
+		if req.URL.Path == "/envelope" {
+			fmt.Fprintf(w, `{"code":0,"msg":"ok","result":{"data":%q}}`, v)
+			return
+		}
+		fmt.Fprintf(w, `{"success":true,"code":0,"message":"ok","data":%q}`, v)
+	})
+
+	parsers := []struct {
+		name   string
+		path   string
+		parser ResponseParser
+	}{
+		{"default", "/default", &DefaultResponseParser{}},
+		{"envelope", "/envelope", &EnvelopeParser{CodePath: "code", MessagePath: "msg", DataPath: "result.data"}},
+	}
+	for _, p := range parsers {
+		t.Run(p.name, func(t *testing.T) {
+			lbc, err := New(addr, &LBConfig{ResponseParser: p.parser})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func(v string) {
+					defer wg.Done()
+					_, data, err := lbc.JPGet(p.path, map[string]string{"v": v})
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if want := strconv.Quote(v); string(data) != want {
+						t.Errorf("data = %s, want %s", data, want)
+					}
+				}(strconv.Itoa(i))
+			}
+			wg.Wait()
+		})
+	}
+}
+
+func TestEnvelopeParser(t *testing.T) {
+	tests := []struct {
+		name    string
+		parser  *EnvelopeParser
+		status  int
+		body    string
+		data    string
+		wantErr bool
+	}{
+		{"default envelope", DefaultEnvelopeParser, 200, `{"success":true,"code":0,"message":"ok","data":{"id":1}}`, `{"id":1}`, false},
+		{"default unsuccess", DefaultEnvelopeParser, 200, `{"success":false,"code":7,"message":"bad","data":null}`, `null`, true},
+		{"nested paths", &EnvelopeParser{CodePath: "meta.code", MessagePath: "meta.msg", DataPath: "result.items"},
+			200, `{"meta":{"code":0,"msg":"ok"},"result":{"items":[1,2]}}`, `[1,2]`, false},
+		{"nested code failure", &EnvelopeParser{CodePath: "meta.code", DataPath: "result"},
+			200, `{"meta":{"code":3},"result":{}}`, `{}`, true},
+		{"string code", &EnvelopeParser{CodePath: "code"}, 200, `{"code":"0"}`, `{"code":"0"}`, false},
+		{"numeric code", &EnvelopeParser{CodePath: "code"}, 200, `{"code":0}`, `{"code":0}`, false},
+		{"custom success code", &EnvelopeParser{CodePath: "code", SuccessCode: "OK", DataPath: "data"},
+			200, `{"code":"OK","data":1}`, `1`, false},
+		{"custom success code failure", &EnvelopeParser{CodePath: "code", SuccessCode: "OK", DataPath: "data"},
+			200, `{"code":0,"data":1}`, `1`, true},
+		{"missing data path", &EnvelopeParser{CodePath: "code", DataPath: "data"}, 200, `{"code":0}`, `null`, false},
+		{"non bool success", &EnvelopeParser{SuccessPath: "success"}, 200, `{"success":"true"}`, `{"success":"true"}`, true},
+		{"missing success", &EnvelopeParser{SuccessPath: "success"}, 200, `{}`, `{}`, true},
+		{"no checks", &EnvelopeParser{}, 200, `[1]`, `[1]`, false},
+		{"status not ok", &EnvelopeParser{CodePath: "code"}, 500, `{"code":0}`, `{"code":0}`, true},
+		{"invalid json", &EnvelopeParser{}, 200, `{`, ``, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode: test.status,
+				Header:     http.Header{},
+				Body:       ioutil.NopCloser(strings.NewReader(test.body)),
+			}
+			statusCode, data, err := test.parser.Parse(resp)
+			if statusCode != test.status {
+				t.Errorf("status = %d, want %d", statusCode, test.status)
+			}
+			if string(data) != test.data {
+				t.Errorf("data = %s, want %s", data, test.data)
+			}
+			if (err != nil) != test.wantErr {
+				t.Errorf("err = %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package gohttplb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+)
+
+// ProblemContentType media type of RFC 7807 problem details
+var ProblemContentType = "application/problem+json"
+
+// ProblemError is RFC 7807 problem details returned by ProblemResponseParser
+type ProblemError struct {
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Extensions members other than the standard ones
+	Extensions map[string]interface{} `json:"-"`
+}
+
+func (e *ProblemError) Error() string {
+	msg := e.Title
+	if msg == "" {
+		msg = e.Type
+	}
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	return fmt.Sprintf("Problem: %d-%s", e.Status, msg)
+}
+
+// UnmarshalJSON decode standard members into fields and others into Extensions
+func (e *ProblemError) UnmarshalJSON(data []byte) error {
+	type problem ProblemError
+	if err := json.Unmarshal(data, (*problem)(e)); err != nil {
+		return err
+	}
+	var members map[string]interface{}
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	for _, key := range []string{"type", "title", "status", "detail", "instance"} {
+		delete(members, key)
+	}
+	e.Extensions = nil
+	if len(members) != 0 {
+		e.Extensions = members
+	}
+	return nil
+}
+
+// ProblemResponseParser return *ProblemError for application/problem+json responses,
+// other responses are parsed by Next. Stateless and safe for concurrent use if Next is.
+type ProblemResponseParser struct {
+	// Next parser of responses not in problem format
+	// Default DefaultResponseParser
+	Next ResponseParser
+}
+
+// Parse implemente interface `ResponseParser.Parse`
+func (parser *ProblemResponseParser) Parse(resp *http.Response) (statusCode int, data []byte, err error) {
+	if !isProblem(resp) {
+		next := parser.Next
+		if next == nil {
+			next = &DefaultResponseParser{}
+		}
+		return next.Parse(resp)
+	}
+
+	defer resp.Body.Close()
+	statusCode = resp.StatusCode
+	if err = decodeResponse(resp); err != nil {
+		return
+	}
+	data, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+
+	problem := &ProblemError{}
+	if err = json.Unmarshal(data, problem); err != nil {
+		return
+	}
+	// absent type is about:blank and status is advisory, see RFC 7807
+	if problem.Type == "" {
+		problem.Type = "about:blank"
+	}
+	if problem.Status == 0 {
+		problem.Status = statusCode
+	}
+	return statusCode, data, problem
+}
+
+func isProblem(resp *http.Response) bool {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get(HeaderContentType))
+	return err == nil && mediaType == ProblemContentType
+}
@@ -0,0 +1,93 @@
+package gohttplb
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func problemResponse(status int, contentType, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{HeaderContentType: []string{contentType}},
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func TestProblemResponseParser(t *testing.T) {
+	body := `{"type":"https://example.com/out-of-credit","title":"Out of credit","status":403,` +
+		`"detail":"balance is 30","instance":"/account/1","balance":30,"accounts":["/account/1"]}`
+	statusCode, data, err := (&ProblemResponseParser{}).Parse(problemResponse(403, "application/problem+json", body))
+	if statusCode != 403 || string(data) != body {
+		t.Fatalf("Parse = %d, %s", statusCode, data)
+	}
+	var problem *ProblemError
+	if !errors.As(err, &problem) {
+		t.Fatalf("err = %v, want *ProblemError", err)
+	}
+	if problem.Type != "https://example.com/out-of-credit" || problem.Title != "Out of credit" ||
+		problem.Status != 403 || problem.Detail != "balance is 30" || problem.Instance != "/account/1" {
+		t.Fatalf("problem = %+v", problem)
+	}
+	if len(problem.Extensions) != 2 || problem.Extensions["balance"] != float64(30) {
+		t.Fatalf("Extensions = %v", problem.Extensions)
+	}
+	if accounts, ok := problem.Extensions["accounts"].([]interface{}); !ok || len(accounts) != 1 {
+		t.Fatalf("accounts = %v", problem.Extensions["accounts"])
+	}
+}
+
+func TestProblemResponseParserDefaults(t *testing.T) {
+	_, _, err := (&ProblemResponseParser{}).Parse(problemResponse(404, "application/problem+json", `{"title":"Not Found"}`))
+	var problem *ProblemError
+	if !errors.As(err, &problem) {
+		t.Fatalf("err = %v, want *ProblemError", err)
+	}
+	if problem.Type != "about:blank" || problem.Status != 404 || problem.Extensions != nil {
+		t.Fatalf("problem = %+v", problem)
+	}
+	if want := "Problem: 404-Not Found"; problem.Error() != want {
+		t.Fatalf("Error() = %q, want %q", problem.Error(), want)
+	}
+}
+
+func TestProblemResponseParserMediaTypeParams(t *testing.T) {
+	resp := problemResponse(400, "application/problem+json; charset=utf-8", `{"status":422,"detail":"bad input"}`)
+	_, _, err := (&ProblemResponseParser{}).Parse(resp)
+	var problem *ProblemError
+	if !errors.As(err, &problem) {
+		t.Fatalf("err = %v, want *ProblemError", err)
+	}
+	// status in body is kept even if it differs from response status
+	if problem.Status != 422 || problem.Detail != "bad input" {
+		t.Fatalf("problem = %+v", problem)
+	}
+}
+
+type recordParser struct {
+	called bool
+}
+
+func (parser *recordParser) Parse(resp *http.Response) (int, []byte, error) {
+	parser.called = true
+	resp.Body.Close()
+	return resp.StatusCode, []byte("next"), nil
+}
+
+func TestProblemResponseParserNext(t *testing.T) {
+	next := &recordParser{}
+	statusCode, data, err := (&ProblemResponseParser{Next: next}).Parse(
+		problemResponse(200, "application/json", `{"title":"not a problem"}`))
+	if !next.called || err != nil || statusCode != 200 || string(data) != "next" {
+		t.Fatalf("Parse = %d, %s, %v, next called %v", statusCode, data, err, next.called)
+	}
+
+	// DefaultResponseParser without Next
+	_, data, err = (&ProblemResponseParser{}).Parse(
+		problemResponse(200, "application/json", `{"success":true,"code":0,"data":"ok"}`))
+	if err != nil || string(data) != `"ok"` {
+		t.Fatalf("Parse = %s, %v", data, err)
+	}
+}
@@ -0,0 +1,160 @@
+package gohttplb
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimitConfig for client side token bucket rate limits, limits apply to every attempt
+// including retries and hedged requests
+type RateLimitConfig struct {
+	// Rate requests per second of LBClient
+	// Disabled if 0
+	Rate float64
+	// Burst requests allowed at once by Rate
+	// Default Rate rounded up
+	Burst int
+	// ServerRate requests per second of each server, multiplied by server weight if ByWeight
+	// Disabled if 0
+	ServerRate float64
+	// ServerBurst requests allowed at once by server rate
+	// Default server rate rounded up
+	ServerBurst int
+	// ServerRates requests per second of servers, scheme can be omitted, overrides ServerRate
+	ServerRates map[string]float64
+	// ByWeight multiply ServerRate by server weight
+	ByWeight bool
+	// Wait wait for token until ctx done, otherwise request is rejected with ErrRateLimited.
+	// Servers without token are skipped if not Wait.
+	Wait bool
+}
+
+// rateLimiter is token bucket
+type rateLimiter struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	// fixedBurst burst set in config, follows rate if false
+	fixedBurst bool
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	l := &rateLimiter{last: time.Now()}
+	l.fixedBurst = burst > 0
+	l.setRate(rate, burst)
+	l.tokens = l.burst
+	return l
+}
+
+// setRate change rate, burst follows rate if not set
+func (l *rateLimiter) setRate(rate float64, burst int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.refill(time.Now())
+	l.rate = rate
+	if !l.fixedBurst {
+		burst = int(math.Ceil(rate))
+	}
+	l.burst = math.Max(float64(burst), 1)
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+}
+
+// refill must hold mutex
+func (l *rateLimiter) refill(now time.Time) {
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens = math.Min(l.burst, l.tokens+elapsed.Seconds()*l.rate)
+		l.last = now
+	}
+}
+
+// wait take a token, wait for it until ctx done if block, otherwise return ErrRateLimited.
+// ErrRateLimited is returned at once if the token can not be available before ctx deadline.
+func (l *rateLimiter) wait(ctx context.Context, block bool) error {
+	if l == nil {
+		return nil
+	}
+	now := time.Now()
+	l.mutex.Lock()
+	l.refill(now)
+	if l.tokens >= 1 {
+		l.tokens--
+		l.mutex.Unlock()
+		return nil
+	}
+	if !block || l.rate <= 0 {
+		l.mutex.Unlock()
+		return ErrRateLimited
+	}
+	delay := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+	if deadline, ok := ctx.Deadline(); ok && deadline.Sub(now) < delay {
+		l.mutex.Unlock()
+		return ErrRateLimited
+	}
+	// reserve token, returned if ctx done before it is available
+	l.tokens--
+	l.mutex.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.mutex.Lock()
+		l.tokens++
+		l.mutex.Unlock()
+		return ctx.Err()
+	}
+}
+
+// serverRate return rate of server, 0 if not limited
+func (conf *RateLimitConfig) serverRate(server string, weight int) float64 {
+	for key, rate := range conf.ServerRates {
+		if key == server || AddSchemeSlice([]string{key})[0] == server {
+			return rate
+		}
+	}
+	if conf.ByWeight {
+		return conf.ServerRate * float64(weight)
+	}
+	return conf.ServerRate
+}
+
+// newServerLimiter return limiter of server, nil if not limited
+func (conf *RateLimitConfig) newServerLimiter(server string, weight int) *rateLimiter {
+	rate := conf.serverRate(server, weight)
+	if rate <= 0 {
+		return nil
+	}
+	return newRateLimiter(rate, conf.ServerBurst)
+}
+
+// waitRate take a token of client rate limit
+func (r *R) waitRate(ctx context.Context) error {
+	if r.limiter == nil {
+		return nil
+	}
+	err := r.limiter.wait(ctx, r.RateLimit.Wait)
+	if err == ErrRateLimited {
+		r.Logger.Warn("gohttplb request rate limited")
+	}
+	return err
+}
+
+// waitServerRate take a token of server rate limit
+func (r *R) waitServerRate(ctx context.Context, n *node) error {
+	if n.limiter == nil {
+		return nil
+	}
+	err := n.limiter.wait(ctx, r.RateLimit.Wait)
+	if err == ErrRateLimited {
+		r.Logger.Debug("gohttplb server rate limited", "server", n.server)
+	}
+	return err
+}
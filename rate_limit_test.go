@@ -0,0 +1,130 @@
+package gohttplb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterWait(t *testing.T) {
+	t.Run("no wait", func(t *testing.T) {
+		l := newRateLimiter(0.001, 1)
+		if err := l.wait(context.Background(), false); err != nil {
+			t.Fatal(err)
+		}
+		if err := l.wait(context.Background(), false); err != ErrRateLimited {
+			t.Fatalf("err = %v, want ErrRateLimited", err)
+		}
+	})
+
+	t.Run("wait", func(t *testing.T) {
+		l := newRateLimiter(100, 1)
+		l.wait(context.Background(), true)
+		start := time.Now()
+		if err := l.wait(context.Background(), true); err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed < 5*time.Millisecond {
+			t.Fatalf("waited %s, want about 10ms", elapsed)
+		}
+	})
+
+	t.Run("canceled wait returns token", func(t *testing.T) {
+		l := newRateLimiter(1, 1)
+		l.wait(context.Background(), true)
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		if err := l.wait(ctx, true); err != context.Canceled {
+			t.Fatalf("err = %v, want context.Canceled", err)
+		}
+		l.mutex.Lock()
+		tokens := l.tokens
+		l.mutex.Unlock()
+		if tokens < 0 {
+			t.Fatalf("tokens = %f, reserved token not returned", tokens)
+		}
+	})
+
+	t.Run("deadline too soon", func(t *testing.T) {
+		l := newRateLimiter(1, 1)
+		l.wait(context.Background(), true)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		if err := l.wait(ctx, true); err != ErrRateLimited {
+			t.Fatalf("err = %v, want ErrRateLimited", err)
+		}
+		if elapsed := time.Since(start); elapsed > 25*time.Millisecond {
+			t.Fatalf("failed after %s, want at once", elapsed)
+		}
+	})
+}
+
+func TestServerRate(t *testing.T) {
+	conf := &RateLimitConfig{
+		ServerRate:  10,
+		ByWeight:    true,
+		ServerRates: map[string]float64{"127.0.0.1:8081": 1},
+	}
+	tests := []struct {
+		server string
+		weight int
+		want   float64
+	}{
+		{"http://127.0.0.1:8080", 1, 10},
+		{"http://127.0.0.1:8080", 3, 30},
+		{"http://127.0.0.1:8081", 3, 1},
+	}
+	for _, tt := range tests {
+		if got := conf.serverRate(tt.server, tt.weight); got != tt.want {
+			t.Errorf("serverRate(%s, %d) = %v, want %v", tt.server, tt.weight, got, tt.want)
+		}
+	}
+}
+
+func TestServerRateFollowsWeight(t *testing.T) {
+	_, addr := newTestServer(t, okHandler)
+	lbc, err := New(addr, &LBConfig{RateLimit: &RateLimitConfig{ServerRate: 10, ByWeight: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = lbc.SetWeight(addr, 3); err != nil {
+		t.Fatal(err)
+	}
+	n, _ := lbc.R.node(addr)
+	n.limiter.mutex.Lock()
+	rate, burst := n.limiter.rate, n.limiter.burst
+	n.limiter.mutex.Unlock()
+	if rate != 30 || burst != 30 {
+		t.Fatalf("rate %v burst %v, want 30 30", rate, burst)
+	}
+}
+
+func TestRateLimitSkipsServerWithoutToken(t *testing.T) {
+	_, addr := newTestServer(t, okHandler)
+	_, other := newTestServer(t, okHandler)
+	lbc, err := New(addr+","+other, &LBConfig{RateLimit: &RateLimitConfig{ServerRate: 0.001}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err := lbc.Get("/")
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		resp.Body.Close()
+	}
+	for _, stats := range lbc.Stats() {
+		if stats.Requests != 1 {
+			t.Fatalf("server %s requests = %d, want 1", stats.Server, stats.Requests)
+		}
+	}
+
+	_, err = lbc.Get("/")
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("err = %v, want RequestError wrapping ErrRateLimited", err)
+	}
+}
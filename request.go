@@ -1,10 +1,11 @@
 package gohttplb
 
 import (
-	"bytes"
-	"io"
+	"context"
 	"net"
 	"net/http"
+	"net/url"
+	"sync"
 	"time"
 )
 
@@ -23,87 +24,524 @@ var defaultTransport = &http.Transport{
 type R struct {
 	servers         []string
 	serverWeighteds []ServerItem
-	scheduler       Scheduler
+	// strategy and scheduler can be changed by setWeight
+	schedulerMutex sync.RWMutex
+	strategy       LoadBalancingStrategy
+	scheduler      Scheduler
+	nodes          map[string]*node
+	cluster        *circuitBreaker
+	budget         *retryBudget
+	limiter        *rateLimiter
+	bulkhead       *bulkhead
+	latency        *latencyWindow
+	metrics        *metrics
+	handler        Handler
+	events         *eventHub
 	*LBConfig
 }
 
 // newR new R
 func newR(servers []string, serverWeighteds []ServerItem, conf *LBConfig) *R {
+	// weighted addrs contain weighted suffix, use server of items instead
+	if len(serverWeighteds) != 0 {
+		servers = make([]string, len(serverWeighteds))
+		for i, item := range serverWeighteds {
+			servers[i] = item.Server
+		}
+	}
+
 	r := &R{
 		servers:         servers,
 		serverWeighteds: serverWeighteds,
+		nodes:           make(map[string]*node, len(servers)),
+		latency:         newLatencyWindow(latencyWindowSize),
+		metrics:         newMetrics(),
+		events:          newEventHub(),
 		LBConfig:        conf,
 	}
+	for _, server := range servers {
+		r.nodes[server] = newNode(server, conf, r.events)
+	}
+	for _, item := range serverWeighteds {
+		r.nodes[item.Server].weight = item.Weighted
+	}
+	if conf.RateLimit != nil {
+		if conf.RateLimit.Rate > 0 {
+			r.limiter = newRateLimiter(conf.RateLimit.Rate, conf.RateLimit.Burst)
+		}
+		for server, n := range r.nodes {
+			n.limiter = conf.RateLimit.newServerLimiter(server, n.weight)
+		}
+	}
+	if conf.ClusterBreaker != nil {
+		r.cluster = newCircuitBreaker("", conf.ClusterBreaker, conf.Logger, r.events)
+	}
+	if conf.RetryBudget != nil {
+		r.budget = newRetryBudget(conf.RetryBudget)
+	}
+	if conf.Bulkhead != nil {
+		r.bulkhead = newBulkhead(conf.Bulkhead)
+	}
 
+	r.handler = chain(conf.Middlewares, conf.Client.Do)
+	r.strategy = conf.Strategy
 	r.scheduler = NewScheduler(conf.Strategy, servers, serverWeighteds)
 	return r
 }
 
-func (r *R) do(rargs *rArgs) (resp *http.Response, err error) {
-	var bodyReader io.Reader
-	if rargs.body != nil {
-		bodyReader = bytes.NewReader(rargs.body)
-	}
-
-	req, err := http.NewRequest(rargs.method, rargs.url, bodyReader)
+func (r *R) do(ctx context.Context, rargs *rArgs, server string) (resp *http.Response, err error) {
+	req, err := http.NewRequestWithContext(ctx, rargs.method, server+rargs.path, nil)
 	if err != nil {
 		return nil, err
 	}
+	if rargs.body != nil {
+		if req.Body, err = rargs.body.open(); err != nil {
+			return nil, err
+		}
+		req.GetBody = rargs.body.getBody
+		req.ContentLength = rargs.body.length
+		if req.ContentLength == 0 {
+			req.Body.Close()
+			req.Body = http.NoBody
+		}
+	}
 
 	if len(rargs.params) != 0 {
 		q := req.URL.Query()
-		for key, val := range rargs.params {
-			q.Add(key, val)
+		for key, vals := range rargs.params {
+			for _, val := range vals {
+				q.Add(key, val)
+			}
 		}
 		req.URL.RawQuery = q.Encode()
 	}
 
-	for key, val := range rargs.headers {
-		req.Header.Set(key, val)
+	for key, vals := range rargs.headers {
+		req.Header[key] = append([]string(nil), vals...)
 	}
+	r.setAcceptEncoding(req)
+	injectTraceContext(ctx, req.Header)
 
-	resp, err = r.Client.Do(req)
+	resp, err = r.handler(req)
+	if err != nil || r.Compression == nil {
+		return
+	}
+	if err = decodeResponse(resp); err != nil {
+		return nil, err
+	}
 	return
 }
 
-func (r *R) doRetry(rA *rArgs) (resp *http.Response, err error) {
-	serversSize := len(r.servers)
-	for i := 0; i < r.Retry*serversSize; i++ {
-		if serversSize == 1 {
-			rA.url = r.servers[0] + rA.path
-		} else {
-			server := r.scheduler.Make()
-			rA.url = server + rA.path
+func (r *R) makeServer() string {
+	r.schedulerMutex.RLock()
+	defer r.schedulerMutex.RUnlock()
+	return r.scheduler.Make()
+}
+
+// setWeight change weight of server, scheduler is rebuilt with StrategyWeightedRoundRobin
+func (r *R) setWeight(server string, weight int) error {
+	n, err := r.node(server)
+	if err != nil {
+		return err
+	}
+	if weight < 1 {
+		return ErrInvalidWeight
+	}
+
+	r.schedulerMutex.Lock()
+	n.setWeight(weight)
+	if n.limiter != nil && r.RateLimit.ByWeight {
+		n.limiter.setRate(r.RateLimit.serverRate(n.server, weight), r.RateLimit.ServerBurst)
+	}
+	items := make([]ServerItem, len(r.servers))
+	for i, server := range r.servers {
+		items[i] = ServerItem{Server: server, Weighted: r.nodes[server].getWeight()}
+	}
+	r.strategy = StrategyWeightedRoundRobin
+	r.scheduler = NewScheduler(r.strategy, r.servers, items)
+	// subscribers may send requests or read strategy, publish outside the lock
+	r.schedulerMutex.Unlock()
+
+	r.Logger.Info("gohttplb server weight changed", "server", n.server, "weight", weight)
+	r.events.publish(Event{Type: EventServerWeightChanged, Server: n.server, Weight: weight})
+	return nil
+}
+
+// node find node of server, scheme can be omitted
+func (r *R) node(server string) (*node, error) {
+	if n, ok := r.nodes[server]; ok {
+		return n, nil
+	}
+	if n, ok := r.nodes[AddSchemeSlice([]string{server})[0]]; ok {
+		return n, nil
+	}
+	return nil, ErrServerNotFound
+}
+
+func (r *R) getStrategy() LoadBalancingStrategy {
+	r.schedulerMutex.RLock()
+	defer r.schedulerMutex.RUnlock()
+	return r.strategy
+}
+
+// pick select an available server after taking rate limit tokens and bulkhead slots,
+// queueing if saturated. Slots must be released by releaseOnClose.
+// Returned error is ErrNoHealthyServers, *ThrottledError, ErrRateLimited, ErrConcurrencyLimited or ctx error
+func (r *R) pick(ctx context.Context, exclude map[string]bool) (n *node, generation uint64, err error) {
+	if err = r.waitRate(ctx); err != nil {
+		return nil, 0, err
+	}
+	if r.bulkhead == nil {
+		return r.pickServer(ctx, exclude)
+	}
+	return r.bulkhead.wait(ctx, func() (*node, uint64, error) {
+		if !r.bulkhead.acquire() {
+			return nil, 0, ErrConcurrencyLimited
 		}
-		// TODO: check response status code 5xx 4xx
-		// TODO: failed server handle
-		resp, err = r.do(rA)
+		n, generation, err := r.pickServer(ctx, exclude)
 		if err != nil {
+			r.bulkhead.releaseClient()
+		}
+		return n, generation, err
+	})
+}
+
+// candidates return iterator of servers to pick, servers made by scheduler first, then servers
+// scheduler did not make, e.g. light servers of weighted round robin. Each server is returned once.
+func (r *R) candidates() func() (string, bool) {
+	serversSize := len(r.servers)
+	visited := make(map[string]bool, serversSize)
+	made, scanned := 0, 0
+	return func() (string, bool) {
+		for made < serversSize {
+			made++
+			server := r.servers[0]
+			if serversSize > 1 {
+				server = r.makeServer()
+			}
+			if !visited[server] {
+				visited[server] = true
+				return server, true
+			}
+		}
+		for scanned < serversSize {
+			server := r.servers[scanned]
+			scanned++
+			if !visited[server] {
+				visited[server] = true
+				return server, true
+			}
+		}
+		return "", false
+	}
+}
+
+// pickServer select an available server from scheduler, servers rejected by circuit breaker,
+// rate limit or bulkhead, ejected, draining, throttled or in exclude are skipped
+func (r *R) pickServer(ctx context.Context, exclude map[string]bool) (n *node, generation uint64, err error) {
+	err = ErrNoHealthyServers
+	now := time.Now()
+	// retryAfter shortest delay of throttled servers
+	var retryAfter time.Duration
+	next := r.candidates()
+	for server, more := next(); more; server, more = next() {
+		if exclude[server] {
+			continue
+		}
+		var ok bool
+		n, ok = r.nodes[server]
+		if !ok {
+			continue
+		}
+		if delay := n.throttledFor(now); delay > 0 {
+			if retryAfter == 0 || delay < retryAfter {
+				retryAfter = delay
+			}
 			continue
 		}
+		if !r.bulkhead.acquireServer(n) {
+			err = ErrConcurrencyLimited
+			continue
+		}
+		if n.limiter != nil {
+			// do not wait for servers that would be rejected anyway
+			if !n.inRotation() || (n.breaker != nil && n.breaker.State() == BreakerOpen) {
+				r.bulkhead.releaseServer(n)
+				continue
+			}
+			if limitErr := r.waitServerRate(ctx, n); limitErr != nil {
+				r.bulkhead.releaseServer(n)
+				if limitErr != ErrRateLimited {
+					return nil, 0, limitErr
+				}
+				if err != ErrConcurrencyLimited {
+					err = limitErr
+				}
+				continue
+			}
+		}
+		if generation, ok = n.allow(); ok {
+			return n, generation, nil
+		}
+		r.bulkhead.releaseServer(n)
+	}
+	if err == ErrNoHealthyServers && retryAfter > 0 {
+		err = &ThrottledError{RetryAfter: retryAfter}
+	}
+	return nil, 0, err
+}
+
+// begin check cluster breaker and record request on retry budget,
+// cancelBegin must be called if no attempt is sent
+func (r *R) begin() (clusterGeneration uint64, err error) {
+	if r.cluster != nil {
+		var ok bool
+		if clusterGeneration, ok = r.cluster.allow(); !ok {
+			return 0, ErrCircuitOpen
+		}
+	}
+	if r.budget != nil {
+		r.budget.deposit()
+	}
+	return
+}
+
+// cancelBegin release cluster breaker probe reserved by begin
+func (r *R) cancelBegin(clusterGeneration uint64) {
+	if r.cluster != nil {
+		r.cluster.cancel(clusterGeneration)
+	}
+}
+
+// canRetry check request deadline, body, cluster breaker and retry budget before a retry
+func (r *R) canRetry(rA *rArgs) error {
+	if err := rA.ctx.Err(); err != nil {
+		return err
+	}
+	if !rA.body.retryable() {
+		return ErrBodyNotReplayable
+	}
+	// fail fast if cluster breaker tripped during retries
+	if r.cluster != nil && r.cluster.State() == BreakerOpen {
+		return ErrCircuitOpen
+	}
+	if r.budget != nil && !r.budget.withdraw() {
+		r.Logger.Warn("gohttplb retry denied by budget", "method", rA.method, "path", rA.path)
+		return ErrRetryBudgetExhausted
+	}
+	r.metrics.addRetry()
+	return nil
+}
+
+// send request to picked node and record result
+func (r *R) send(ctx context.Context, rA *rArgs, n *node, generation, clusterGeneration uint64) (resp *http.Response, err error) {
+	var cancel context.CancelFunc
+	if rA.perTryTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, rA.perTryTimeout)
+	}
+
+	ctx = rA.attemptContext(ctx, n.server)
+	info, _ := AttemptFromContext(ctx)
+	r.Logger.Debug("gohttplb server selected", "server", n.server, "attempt", info.Attempt,
+		"method", rA.method, "path", rA.path)
+
+	if r.Tracer != nil {
+		var span Span
+		ctx, span = r.startAttemptSpan(ctx, rA, n.server, info.Attempt)
+		defer func() {
+			endAttemptSpan(span, resp, err)
+		}()
+	}
+
+	start := time.Now()
+	n.start()
+	resp, err = r.do(ctx, rA, n.server)
+	n.finish()
+	duration := time.Since(start)
+	rA.addAttempt(n.server, resp, err, duration)
+	if err == nil {
+		r.throttle(n, resp)
+	}
+	r.metrics.addAttempt(n.server, rA.method, resp, err, duration)
+	if err != nil {
+		r.Logger.Warn("gohttplb attempt failed", "server", n.server, "attempt", info.Attempt,
+			"method", rA.method, "path", rA.path, "duration", duration, "error", err)
+	} else if resp.StatusCode >= http.StatusInternalServerError {
+		r.Logger.Warn("gohttplb attempt failed", "server", n.server, "attempt", info.Attempt,
+			"method", rA.method, "path", rA.path, "duration", duration, "status", resp.StatusCode)
+	}
+	if cancel != nil {
+		if err != nil {
+			cancel()
+		} else {
+			resp = withCancelBody(resp, cancel)
+		}
+	}
+	n.done(generation, resp, err, duration)
+	resp = r.releaseOnClose(resp, err, n)
+	if r.cluster != nil {
+		if canceled(err) {
+			r.cluster.cancel(clusterGeneration)
+		} else {
+			r.cluster.done(clusterGeneration, r.cluster.conf.IsFailure(resp, err))
+		}
+	}
+	if err == nil {
+		r.latency.add(time.Since(start))
+	}
+	return
+}
+
+func (r *R) doRetry(rA *rArgs) (resp *http.Response, err error) {
+	if rA.timeout <= 0 {
+		return r.retry(rA)
+	}
+
+	ctx, cancel := context.WithTimeout(rA.ctx, rA.timeout)
+	rA.ctx = ctx
+	resp, err = r.retry(rA)
+	if resp == nil {
+		cancel()
 		return
 	}
+	return withCancelBody(resp, cancel), err
+}
+
+// retry send request until success or retries exhausted,
+// returned error is *RequestError
+func (r *R) retry(rA *rArgs) (resp *http.Response, err error) {
+	defer rA.body.close()
+	if err = r.compressBody(rA); err != nil {
+		return nil, rA.requestError(err)
+	}
+	resp, err = r.tryServers(rA)
+	r.metrics.addRequest(rA.method, resp, err)
+	if err != nil {
+		reqErr := rA.requestError(err)
+		r.Logger.Error("gohttplb request failed", "method", rA.method, "path", rA.path,
+			"attempts", len(reqErr.Attempts), "error", err)
+		return nil, reqErr
+	}
 	return
 }
 
+// tryServers send request, returned error is the reason of failure:
+// ErrAllServersFailed, ErrNoHealthyServers, ErrCircuitOpen, ErrRetryBudgetExhausted,
+// *ThrottledError, ErrRateLimited, ErrConcurrencyLimited, ErrBodyNotReplayable or ctx error
+func (r *R) tryServers(rA *rArgs) (resp *http.Response, err error) {
+	clusterGeneration, err := r.begin()
+	if err != nil {
+		return nil, err
+	}
+	if r.Hedge != nil && rA.idempotent() && rA.body.replayable() {
+		return r.doHedged(rA, clusterGeneration)
+	}
+
+	serversSize := len(r.servers)
+	reason := ErrAllServersFailed
+	// throttled whether resp throttled its server
+	throttled := false
+	for i := 0; i < rA.retry*serversSize; i++ {
+		if i > 0 {
+			if err = r.canRetry(rA); err != nil {
+				reason = err
+				break
+			}
+		}
+		n, generation, pickErr := r.pick(rA.ctx, nil)
+		if pickErr != nil {
+			if i == 0 {
+				r.cancelBegin(clusterGeneration)
+			}
+			if i == 0 || pickErr != ErrNoHealthyServers {
+				reason = pickErr
+			}
+			break
+		}
+		if i > 0 {
+			r.Logger.Info("gohttplb retry", "server", n.server, "method", rA.method, "path", rA.path)
+		}
+		// discard previous response with retry status
+		if resp != nil {
+			resp.Body.Close()
+		}
+		resp, err = r.send(rA.ctx, rA, n, generation, clusterGeneration)
+		throttled = r.throttledBy(n, resp)
+		if err != nil || rA.retryStatusCode(resp) || throttled {
+			continue
+		}
+		return
+	}
+
+	// return last response with retry status if retries exhausted,
+	// or ThrottledError if it throttled the last server available
+	if resp != nil {
+		if throttled {
+			if throttledErr := r.throttledError(); throttledErr != nil {
+				resp.Body.Close()
+				return nil, throttledErr
+			}
+		}
+		return resp, nil
+	}
+	return nil, reason
+}
+
+func (rA *rArgs) retryStatusCode(resp *http.Response) bool {
+	return len(rA.retryStatus) != 0 && ExistIntSlice(resp.StatusCode, rA.retryStatus)
+}
+
 type rArgs struct {
-	url     string
+	ctx     context.Context
 	method  string
 	path    string
-	params  map[string]string
-	headers map[string]string
-	body    []byte
+	params  url.Values
+	headers http.Header
+	body    *requestBody
+	// timeout total request deadline, perTryTimeout deadline of each attempt
+	timeout       time.Duration
+	perTryTimeout time.Duration
+	// retry times of all servers, retryStatus response status codes to retry
+	retry       int
+	retryStatus []int
+	// attemptNumber number of last attempt started
+	attemptNumber int32
+
+	// attempts sent, guarded by attemptsMutex for hedged requests
+	attemptsMutex sync.Mutex
+	attempts      []Attempt
 }
 
 func (r *R) doRequest(method, path string, params, headers map[string]string, body []byte) (resp *http.Response, err error) {
-	rA := &rArgs{
-		method:  method,
-		path:    path,
-		params:  params,
-		headers: headers,
-		body:    body,
+	return r.doRequestContext(context.Background(), method, path, params, headers, body)
+}
+
+func (r *R) doRequestContext(ctx context.Context, method, path string, params, headers map[string]string, body []byte) (resp *http.Response, err error) {
+	rA := r.newRArgs(ctx, method, path)
+	for key, val := range params {
+		rA.params.Set(key, val)
+	}
+	for key, val := range headers {
+		rA.headers.Set(key, val)
+	}
+	rA.body = bytesBody(body)
+	rA.overrideTimeouts(ctx)
+	return r.doCall(rA)
+}
+
+// newRArgs new rArgs with LBConfig defaults
+func (r *R) newRArgs(ctx context.Context, method, path string) *rArgs {
+	return &rArgs{
+		ctx:           ctx,
+		method:        method,
+		path:          path,
+		params:        make(url.Values),
+		headers:       make(http.Header),
+		timeout:       r.RequestTimeout,
+		perTryTimeout: r.PerTryTimeout,
+		retry:         r.Retry,
+		retryStatus:   r.RetryStatus,
 	}
-	return r.doRetry(rA)
 }
 
 func (r *R) get(method, path string, params map[string]string, headers map[string]string) (resp *http.Response, err error) {
@@ -129,12 +567,12 @@ func (r *R) patch(method, path string, params map[string]string, headers map[str
 func (r *R) parseDo(method, path string, params map[string]string, headers map[string]string, body []byte) (statusCode int, data []byte, err error) {
 	response, err := r.doRequest(method, path, params, headers, body)
 	if err != nil {
-		return response.StatusCode, nil, err
+		return 0, nil, err
 	}
+	return r.parseResponse(response)
+}
 
-	if r.ResponseParser == nil {
-		r.ResponseParser = &DefaultResponseParser{}
-	}
+func (r *R) parseResponse(response *http.Response) (statusCode int, data []byte, err error) {
 	return r.ResponseParser.Parse(response)
 }
 
@@ -0,0 +1,180 @@
+package gohttplb
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// RequestBuilder build a request step by step, e.g.
+//
+//	resp, err := lbclient.NewRequest().Method(http.MethodGet).Path("/hello").
+//		Query(url.Values{"id": {"1", "2"}}).Header("X-Token", "token").Do(ctx)
+//
+// Method default GET, overrides default to LBConfig.
+type RequestBuilder struct {
+	r       *R
+	method  string
+	path    string
+	params  url.Values
+	headers http.Header
+	body    *requestBody
+
+	timeout          time.Duration
+	timeoutSet       bool
+	perTryTimeout    time.Duration
+	perTryTimeoutSet bool
+	retry            int
+	retryStatus      []int
+	retryStatusSet   bool
+}
+
+// NewRequest new RequestBuilder
+func (lbc *LBClient) NewRequest() *RequestBuilder {
+	return &RequestBuilder{
+		r:       lbc.R,
+		method:  http.MethodGet,
+		params:  make(url.Values),
+		headers: make(http.Header),
+	}
+}
+
+// Method set request method
+func (rb *RequestBuilder) Method(method string) *RequestBuilder {
+	rb.method = method
+	return rb
+}
+
+// Path set request path
+func (rb *RequestBuilder) Path(path string) *RequestBuilder {
+	rb.path = path
+	return rb
+}
+
+// Query add query params, multi-valued params are kept
+func (rb *RequestBuilder) Query(params url.Values) *RequestBuilder {
+	for key, vals := range params {
+		rb.params[key] = append(rb.params[key], vals...)
+	}
+	return rb
+}
+
+// Param add a query param value
+func (rb *RequestBuilder) Param(key, value string) *RequestBuilder {
+	rb.params.Add(key, value)
+	return rb
+}
+
+// Header add header values, repeated header is kept
+func (rb *RequestBuilder) Header(key string, values ...string) *RequestBuilder {
+	for _, value := range values {
+		rb.headers.Add(key, value)
+	}
+	return rb
+}
+
+// Headers add headers
+func (rb *RequestBuilder) Headers(headers http.Header) *RequestBuilder {
+	for key, vals := range headers {
+		rb.Header(key, vals...)
+	}
+	return rb
+}
+
+// Body set request body
+func (rb *RequestBuilder) Body(body []byte) *RequestBuilder {
+	rb.body = bytesBody(body)
+	return rb
+}
+
+// BodyReader set streaming request body. *bytes.Buffer, *bytes.Reader and *strings.Reader
+// are replayable, other readers can not be retried once read and fail with ErrBodyNotReplayable.
+// reader is closed when request finished if it is an io.Closer
+func (rb *RequestBuilder) BodyReader(reader io.Reader) *RequestBuilder {
+	rb.body = readerBody(reader)
+	return rb
+}
+
+// BodyFunc set replayable streaming request body, getBody is called for every attempt
+// and may be called concurrently by hedged requests. length is -1 if unknown.
+func (rb *RequestBuilder) BodyFunc(getBody func() (io.ReadCloser, error), length int64) *RequestBuilder {
+	rb.body = funcBody(getBody, length)
+	return rb
+}
+
+// JSON set json Content-Type and Accept headers
+func (rb *RequestBuilder) JSON() *RequestBuilder {
+	rb.headers.Set(HeaderContentType, DefaultContentType)
+	rb.headers.Set(HeaderAccept, DefaultAccept)
+	return rb
+}
+
+// Timeout override LBConfig.RequestTimeout
+func (rb *RequestBuilder) Timeout(timeout time.Duration) *RequestBuilder {
+	rb.timeout = timeout
+	rb.timeoutSet = true
+	return rb
+}
+
+// PerTryTimeout override LBConfig.PerTryTimeout
+func (rb *RequestBuilder) PerTryTimeout(timeout time.Duration) *RequestBuilder {
+	rb.perTryTimeout = timeout
+	rb.perTryTimeoutSet = true
+	return rb
+}
+
+// Retry override LBConfig.Retry
+func (rb *RequestBuilder) Retry(retry int) *RequestBuilder {
+	rb.retry = retry
+	return rb
+}
+
+// RetryStatus override LBConfig.RetryStatus
+func (rb *RequestBuilder) RetryStatus(statusCodes ...int) *RequestBuilder {
+	rb.retryStatus = statusCodes
+	rb.retryStatusSet = true
+	return rb
+}
+
+// rArgs build rArgs for a request, timeouts set in ctx are overridden by builder
+func (rb *RequestBuilder) rArgs(ctx context.Context) *rArgs {
+	rA := rb.r.newRArgs(ctx, rb.method, rb.path)
+	for key, vals := range rb.params {
+		rA.params[key] = append([]string(nil), vals...)
+	}
+	for key, vals := range rb.headers {
+		rA.headers[key] = append([]string(nil), vals...)
+	}
+	rA.body = rb.body
+
+	rA.overrideTimeouts(ctx)
+	if rb.timeoutSet {
+		rA.timeout = rb.timeout
+	}
+	if rb.perTryTimeoutSet {
+		rA.perTryTimeout = rb.perTryTimeout
+	}
+	if rb.retry > 0 {
+		rA.retry = rb.retry
+	}
+	if rb.retryStatusSet {
+		rA.retryStatus = rb.retryStatus
+	}
+	return rA
+}
+
+// Do send request
+func (rb *RequestBuilder) Do(ctx context.Context) (*http.Response, error) {
+	return rb.r.doCall(rb.rArgs(ctx))
+}
+
+// DoParse send request and parse response with LBConfig.ResponseParser
+func (rb *RequestBuilder) DoParse(ctx context.Context) (statusCode int, data []byte, err error) {
+	resp, err := rb.Do(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	return rb.r.parseResponse(resp)
+}
@@ -0,0 +1,79 @@
+package gohttplb
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Attempt is result of sending request to a server
+type Attempt struct {
+	// Server request server
+	Server string
+	// StatusCode response status code, 0 if no response
+	StatusCode int
+	// Err request error
+	Err error
+	// Duration from sending request to receiving response header or error
+	Duration time.Duration
+}
+
+func (a Attempt) String() string {
+	if a.Err != nil {
+		return fmt.Sprintf("%s: %s (%s)", a.Server, a.Err, a.Duration)
+	}
+	return fmt.Sprintf("%s: status %d (%s)", a.Server, a.StatusCode, a.Duration)
+}
+
+// RequestError is returned when request failed, use errors.As to get attempts
+// and errors.Is to check reason like ErrAllServersFailed
+type RequestError struct {
+	Method string
+	Path   string
+	// Attempts sent in completion order
+	Attempts []Attempt
+	// Err reason of failure checked by errors.Is: ErrAllServersFailed, ErrNoHealthyServers,
+	// ErrCircuitOpen, ErrRetryBudgetExhausted, ErrBodyNotReplayable, ErrRateLimited,
+	// ErrConcurrencyLimited or context error, or *ThrottledError checked by errors.As
+	Err error
+}
+
+func (e *RequestError) Error() string {
+	if len(e.Attempts) == 0 {
+		return fmt.Sprintf("%s %s: %s", e.Method, e.Path, e.Err)
+	}
+	attempts := make([]string, len(e.Attempts))
+	for i, attempt := range e.Attempts {
+		attempts[i] = attempt.String()
+	}
+	return fmt.Sprintf("%s %s: %s: [%s]", e.Method, e.Path, e.Err, strings.Join(attempts, "; "))
+}
+
+// Unwrap return reason of failure
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+func (rA *rArgs) addAttempt(server string, resp *http.Response, err error, duration time.Duration) {
+	attempt := Attempt{Server: server, Err: err, Duration: duration}
+	if resp != nil {
+		attempt.StatusCode = resp.StatusCode
+	}
+	rA.attemptsMutex.Lock()
+	rA.attempts = append(rA.attempts, attempt)
+	rA.attemptsMutex.Unlock()
+}
+
+func (rA *rArgs) requestError(err error) *RequestError {
+	rA.attemptsMutex.Lock()
+	defer rA.attemptsMutex.Unlock()
+	attempts := make([]Attempt, len(rA.attempts))
+	copy(attempts, rA.attempts)
+	return &RequestError{
+		Method:   rA.method,
+		Path:     rA.path,
+		Attempts: attempts,
+		Err:      err,
+	}
+}
@@ -0,0 +1,77 @@
+package gohttplb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestServer(t *testing.T, handler http.HandlerFunc) (server *httptest.Server, addr string) {
+	t.Helper()
+	server = httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server, strings.TrimPrefix(server.URL, "http://")
+}
+
+func okHandler(w http.ResponseWriter, req *http.Request) {
+	w.Write([]byte("ok"))
+}
+
+func TestPickSkipsUnavailableHeavyWeightedServer(t *testing.T) {
+	_, heavy := newTestServer(t, okHandler)
+	_, light := newTestServer(t, okHandler)
+
+	lbc, err := New(heavy+"@@5,"+light+"@@1", &LBConfig{Retry: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = lbc.Eject(heavy, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 12; i++ {
+		resp, err := lbc.Get("/")
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		resp.Body.Close()
+	}
+	for _, stats := range lbc.Stats() {
+		if strings.HasSuffix(stats.Server, light) && stats.Requests != 12 {
+			t.Fatalf("light server requests = %d, want 12", stats.Requests)
+		}
+	}
+}
+
+func TestPickSkipsOpenBreakerOfHeavyWeightedServer(t *testing.T) {
+	_, heavy := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	_, light := newTestServer(t, okHandler)
+
+	lbc, err := New(heavy+"@@5,"+light+"@@1", &LBConfig{
+		Retry:   1,
+		Breaker: &BreakerConfig{ConsecutiveFailures: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// trip breaker of heavy server, it is picked first
+	resp, err := lbc.Get("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	for i := 0; i < 12; i++ {
+		resp, err := lbc.Get("/")
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: status %d, want 200", i, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package gohttplb
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Default retry budget config
+var (
+	DefaultRetryBudgetRatio               = 0.2
+	DefaultRetryBudgetMinRetriesPerSecond = 10.0
+	DefaultRetryBudgetMaxTokens           = 100.0
+)
+
+// RetryBudgetConfig for token bucket retry budget shared by all requests of LBClient.
+// Every request deposits Ratio token, every retry withdraws one token.
+type RetryBudgetConfig struct {
+	// Ratio of retries allowed per request
+	// Default 0.2
+	Ratio float64
+	// MinRetriesPerSecond token refilled per second regardless of requests,
+	// keep low traffic clients retrying
+	// Default 10
+	MinRetriesPerSecond float64
+	// MaxTokens max tokens in bucket, limit retry burst
+	// Default 100
+	MaxTokens float64
+}
+
+func setDefaultRetryBudgetConf(conf *RetryBudgetConfig) {
+	if conf.Ratio == 0 {
+		conf.Ratio = DefaultRetryBudgetRatio
+	}
+	if conf.MinRetriesPerSecond == 0 {
+		conf.MinRetriesPerSecond = DefaultRetryBudgetMinRetriesPerSecond
+	}
+	if conf.MaxTokens == 0 {
+		conf.MaxTokens = DefaultRetryBudgetMaxTokens
+	}
+}
+
+// RetryBudgetStats is retry budget counters
+type RetryBudgetStats struct {
+	// Requests logical requests
+	Requests uint64
+	// Retries allowed retries
+	Retries uint64
+	// Denied retries denied by budget
+	Denied uint64
+}
+
+type retryBudget struct {
+	conf *RetryBudgetConfig
+
+	mutex  sync.Mutex
+	tokens float64
+	last   time.Time
+
+	requests uint64
+	retries  uint64
+	denied   uint64
+}
+
+func newRetryBudget(conf *RetryBudgetConfig) *retryBudget {
+	return &retryBudget{
+		conf:   conf,
+		tokens: conf.MaxTokens,
+		last:   time.Now(),
+	}
+}
+
+// deposit record a request
+func (b *retryBudget) deposit() {
+	atomic.AddUint64(&b.requests, 1)
+	b.mutex.Lock()
+	b.refill(time.Now())
+	b.tokens += b.conf.Ratio
+	if b.tokens > b.conf.MaxTokens {
+		b.tokens = b.conf.MaxTokens
+	}
+	b.mutex.Unlock()
+}
+
+// withdraw reports whether a retry is allowed
+func (b *retryBudget) withdraw() bool {
+	b.mutex.Lock()
+	b.refill(time.Now())
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	b.mutex.Unlock()
+
+	if allowed {
+		atomic.AddUint64(&b.retries, 1)
+	} else {
+		atomic.AddUint64(&b.denied, 1)
+	}
+	return allowed
+}
+
+// refill must hold mutex
+func (b *retryBudget) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.conf.MinRetriesPerSecond
+	if b.tokens > b.conf.MaxTokens {
+		b.tokens = b.conf.MaxTokens
+	}
+	b.last = now
+}
+
+func (b *retryBudget) stats() RetryBudgetStats {
+	return RetryBudgetStats{
+		Requests: atomic.LoadUint64(&b.requests),
+		Retries:  atomic.LoadUint64(&b.retries),
+		Denied:   atomic.LoadUint64(&b.denied),
+	}
+}
@@ -0,0 +1,40 @@
+package gohttplb
+
+import "time"
+
+// ServerStats is runtime stats snapshot of a server
+type ServerStats struct {
+	Server string
+	// Requests attempts sent to server
+	Requests uint64
+	// Errors failed attempts, see BreakerConfig.IsFailure
+	Errors uint64
+	// InFlight attempts waiting for response
+	InFlight int64
+	// EWMALatency exponentially weighted moving average of attempt latency
+	EWMALatency time.Duration
+	// LastError last failure and its time
+	LastError     error
+	LastErrorTime time.Time
+	// State breaker state, BreakerClosed if LBConfig.Breaker not set
+	State BreakerState
+	// Ejected server is taken out of rotation
+	Ejected bool
+	// Draining server takes no new requests
+	Draining bool
+	// ThrottledUntil server is skipped until it by Retry-After, zero if not throttled
+	ThrottledUntil time.Time
+	// Weight effective weight, 1 if not weighted
+	Weight int
+	// ConcurrencyLimit current adaptive concurrency limit, 0 if not adaptive
+	ConcurrencyLimit int
+}
+
+// Stats return stats snapshot of all servers
+func (lbc *LBClient) Stats() []ServerStats {
+	stats := make([]ServerStats, len(lbc.R.servers))
+	for i, server := range lbc.R.servers {
+		stats[i] = lbc.R.nodes[server].stats()
+	}
+	return stats
+}
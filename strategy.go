@@ -10,6 +10,16 @@ const (
 	StrategyWeightedRoundRobin
 )
 
+func (s LoadBalancingStrategy) String() string {
+	switch s {
+	case StrategyRoundRobin:
+		return "round-robin"
+	case StrategyWeightedRoundRobin:
+		return "weighted-round-robin"
+	}
+	return "unknown"
+}
+
 // Scheduler make a valid server for Request
 type Scheduler interface {
 	Make() string
@@ -11,8 +11,8 @@ type RoundRobinMaker struct {
 
 // Make implement Scheduler interface
 func (maker *RoundRobinMaker) Make() (server string) {
-	// maker.mutex.Lock()
-	// defer maker.mutex.Unlock()
+	maker.mutex.Lock()
+	defer maker.mutex.Unlock()
 	server = maker.servers[maker.next]
 	maker.next = (maker.next + 1) % len(maker.servers)
 	return
@@ -1,5 +1,7 @@
 package gohttplb
 
+import "sync"
+
 // ServerItem is server item with weighted
 type ServerItem struct {
 	Server   string
@@ -19,6 +21,8 @@ type WeightedRoundRobinMaker struct {
 	gcdW int
 	// max weighted
 	maxW int
+	// guard index and curW for concurrent Make
+	mutex sync.Mutex
 }
 
 // NewWeightedRoundRobinMaker new WeightedRoundRobinMaker and init
@@ -27,7 +31,7 @@ func NewWeightedRoundRobinMaker(servers []ServerItem) *WeightedRoundRobinMaker {
 		return nil
 	}
 
-	maker := new(WeightedRoundRobinMaker)
+	maker := &WeightedRoundRobinMaker{servers: servers}
 	for _, server := range maker.servers {
 		if maker.gcdW == 0 {
 			maker.n = len(maker.servers)
@@ -55,6 +59,8 @@ func (maker *WeightedRoundRobinMaker) Make() (server string) {
 		return maker.servers[0].Server
 	}
 
+	maker.mutex.Lock()
+	defer maker.mutex.Unlock()
 	for {
 		maker.index = (maker.index + 1) % maker.n
 		if maker.index == 0 {
@@ -0,0 +1,115 @@
+package gohttplb
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HeaderRetryAfter response header of throttle delay
+var HeaderRetryAfter = "Retry-After"
+
+// Default throttle config
+var (
+	DefaultThrottleStatusCodes = []int{http.StatusTooManyRequests, http.StatusServiceUnavailable}
+	DefaultThrottleMaxDelay    = 5 * time.Minute
+)
+
+// ThrottleConfig for servers asking clients to back off with Retry-After
+type ThrottleConfig struct {
+	// StatusCodes responses with these status codes and Retry-After header throttle server,
+	// throttled servers are skipped until Retry-After elapsed
+	// Default 429, 503
+	StatusCodes []int
+	// DefaultDelay throttle delay of responses with StatusCodes but without valid Retry-After
+	// Disabled if 0
+	DefaultDelay time.Duration
+	// MaxDelay limit delay of Retry-After
+	// Default 5m
+	MaxDelay time.Duration
+}
+
+func setDefaultThrottleConf(conf *ThrottleConfig) {
+	if len(conf.StatusCodes) == 0 {
+		conf.StatusCodes = DefaultThrottleStatusCodes
+	}
+	if conf.MaxDelay == 0 {
+		conf.MaxDelay = DefaultThrottleMaxDelay
+	}
+}
+
+// ThrottledError is returned when all servers are throttled by Retry-After
+type ThrottledError struct {
+	// RetryAfter time until first server is available again
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("all servers throttled, retry after %s", e.RetryAfter)
+}
+
+// parseRetryAfter parse Retry-After in seconds or HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// throttle mark node throttled if resp asks to back off
+func (r *R) throttle(n *node, resp *http.Response) {
+	if r.Throttle == nil || !ExistIntSlice(resp.StatusCode, r.Throttle.StatusCodes) {
+		return
+	}
+	now := time.Now()
+	delay, ok := parseRetryAfter(resp.Header.Get(HeaderRetryAfter), now)
+	if !ok {
+		delay = r.Throttle.DefaultDelay
+	}
+	if delay > r.Throttle.MaxDelay {
+		delay = r.Throttle.MaxDelay
+	}
+	if delay <= 0 {
+		return
+	}
+	n.throttle(now.Add(delay))
+	r.Logger.Warn("gohttplb server throttled", "server", n.server, "status", resp.StatusCode, "delay", delay)
+	r.events.publish(Event{Type: EventServerThrottled, Server: n.server, Time: now, Duration: delay})
+}
+
+// throttledBy reports whether resp throttled n, the request is then retried on another server
+func (r *R) throttledBy(n *node, resp *http.Response) bool {
+	return r.Throttle != nil && resp != nil && ExistIntSlice(resp.StatusCode, r.Throttle.StatusCodes) &&
+		n.throttledFor(time.Now()) > 0
+}
+
+// throttledError return *ThrottledError with the shortest delay if all servers are throttled, nil otherwise
+func (r *R) throttledError() error {
+	now := time.Now()
+	var retryAfter time.Duration
+	for _, n := range r.nodes {
+		delay := n.throttledFor(now)
+		if delay <= 0 {
+			return nil
+		}
+		if retryAfter == 0 || delay < retryAfter {
+			retryAfter = delay
+		}
+	}
+	return &ThrottledError{RetryAfter: retryAfter}
+}
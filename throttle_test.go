@@ -0,0 +1,91 @@
+package gohttplb
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value string
+		delay time.Duration
+		ok    bool
+	}{
+		{"seconds", "120", 2 * time.Minute, true},
+		{"seconds with spaces", " 3 ", 3 * time.Second, true},
+		{"zero seconds", "0", 0, true},
+		{"http date", now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second, true},
+		{"past http date", now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"empty", "", 0, false},
+		{"negative", "-1", 0, false},
+		{"invalid", "soon", 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			delay, ok := parseRetryAfter(test.value, now)
+			if delay != test.delay || ok != test.ok {
+				t.Fatalf("parseRetryAfter(%q) = %s, %v, want %s, %v", test.value, delay, ok, test.delay, test.ok)
+			}
+		})
+	}
+}
+
+func throttledHandler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set(HeaderRetryAfter, "60")
+	w.WriteHeader(http.StatusTooManyRequests)
+}
+
+func TestThrottledServerIsSkipped(t *testing.T) {
+	throttledHits := 0
+	_, throttled := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		throttledHits++
+		throttledHandler(w, req)
+	})
+	_, healthy := newTestServer(t, okHandler)
+
+	lbc, err := New(throttled+","+healthy, &LBConfig{Throttle: &ThrottleConfig{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 6; i++ {
+		resp, err := lbc.Get("/")
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: status %d, want %d", i, resp.StatusCode, http.StatusOK)
+		}
+	}
+	if throttledHits != 1 {
+		t.Fatalf("throttled server got %d requests, want 1", throttledHits)
+	}
+}
+
+func TestAllServersThrottled(t *testing.T) {
+	_, first := newTestServer(t, throttledHandler)
+	_, second := newTestServer(t, throttledHandler)
+
+	lbc, err := New(first+","+second, &LBConfig{Throttle: &ThrottleConfig{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		resp, err := lbc.Get("/")
+		if resp != nil {
+			resp.Body.Close()
+			t.Fatalf("request %d: unexpected response %d", i, resp.StatusCode)
+		}
+		var throttledErr *ThrottledError
+		if !errors.As(err, &throttledErr) {
+			t.Fatalf("request %d: err = %v, want *ThrottledError", i, err)
+		}
+		if throttledErr.RetryAfter <= 0 || throttledErr.RetryAfter > time.Minute {
+			t.Fatalf("request %d: RetryAfter = %s", i, throttledErr.RetryAfter)
+		}
+	}
+}
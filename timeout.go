@@ -0,0 +1,33 @@
+package gohttplb
+
+import (
+	"context"
+	"time"
+)
+
+type timeoutKey int
+
+const (
+	requestTimeoutKey timeoutKey = iota
+	perTryTimeoutKey
+)
+
+// WithRequestTimeout return ctx overriding LBConfig.RequestTimeout for a request
+func WithRequestTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, requestTimeoutKey, timeout)
+}
+
+// WithPerTryTimeout return ctx overriding LBConfig.PerTryTimeout for a request
+func WithPerTryTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, perTryTimeoutKey, timeout)
+}
+
+// overrideTimeouts apply timeouts set in ctx
+func (rA *rArgs) overrideTimeouts(ctx context.Context) {
+	if timeout, ok := ctx.Value(requestTimeoutKey).(time.Duration); ok {
+		rA.timeout = timeout
+	}
+	if timeout, ok := ctx.Value(perTryTimeoutKey).(time.Duration); ok {
+		rA.perTryTimeout = timeout
+	}
+}
@@ -0,0 +1,39 @@
+package gohttplb
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestClientTimeoutNotUsedWithRequestTimeouts(t *testing.T) {
+	_, addr := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	})
+
+	tests := []struct {
+		name    string
+		conf    LBConfig
+		wantErr bool
+	}{
+		{"client timeout", LBConfig{ClientTimeout: 20 * time.Millisecond}, true},
+		{"per try timeout", LBConfig{ClientTimeout: 20 * time.Millisecond, PerTryTimeout: time.Second}, false},
+		{"request timeout", LBConfig{ClientTimeout: 20 * time.Millisecond, RequestTimeout: time.Second}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := tt.conf
+			lbc, err := New(addr, &conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := lbc.Get("/")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil {
+				resp.Body.Close()
+			}
+		})
+	}
+}
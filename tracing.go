@@ -0,0 +1,126 @@
+package gohttplb
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+)
+
+// W3C trace context headers
+var (
+	HeaderTraceParent = "traceparent"
+	HeaderTraceState  = "tracestate"
+)
+
+// Tracer start spans, implement it to use OpenTelemetry or other tracing vendors.
+// Span started by Start must be child of span in ctx if any.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a traced operation
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+	// SpanContext return trace context propagated to servers
+	SpanContext() SpanContext
+}
+
+// SpanContext is W3C trace context of span
+type SpanContext struct {
+	TraceID    [16]byte
+	SpanID     [8]byte
+	Sampled    bool
+	TraceState string
+}
+
+// IsValid reports whether trace id and span id are not zero
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// TraceParent format W3C traceparent header value
+func (sc SpanContext) TraceParent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-" + flags
+}
+
+// Span names and attributes
+const (
+	spanNameRequest = "gohttplb.request"
+	spanNameAttempt = "gohttplb.attempt"
+
+	attrMethod     = "http.request.method"
+	attrPath       = "url.path"
+	attrStatusCode = "http.response.status_code"
+	attrServer     = "server.address"
+	attrAttempt    = "gohttplb.attempt"
+	attrAttempts   = "gohttplb.attempts"
+)
+
+type spanKey struct{}
+
+// startCallSpan start span of a request, returned ctx contains it
+func (r *R) startCallSpan(rA *rArgs) (context.Context, Span) {
+	ctx, span := r.Tracer.Start(rA.ctx, spanNameRequest)
+	span.SetAttribute(attrMethod, rA.method)
+	span.SetAttribute(attrPath, rA.path)
+	return ctx, span
+}
+
+func endCallSpan(span Span, resp *http.Response, err error) {
+	if resp != nil {
+		span.SetAttribute(attrStatusCode, resp.StatusCode)
+	}
+	var reqErr *RequestError
+	if errors.As(err, &reqErr) {
+		span.SetAttribute(attrAttempts, len(reqErr.Attempts))
+	}
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// startAttemptSpan start span of an attempt, returned ctx contains it for propagation
+func (r *R) startAttemptSpan(ctx context.Context, rA *rArgs, server string, attempt int) (context.Context, Span) {
+	ctx, span := r.Tracer.Start(ctx, spanNameAttempt)
+	span.SetAttribute(attrMethod, rA.method)
+	span.SetAttribute(attrPath, rA.path)
+	span.SetAttribute(attrServer, server)
+	span.SetAttribute(attrAttempt, attempt)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func endAttemptSpan(span Span, resp *http.Response, err error) {
+	if resp != nil {
+		span.SetAttribute(attrStatusCode, resp.StatusCode)
+	}
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// injectTraceContext set traceparent and tracestate headers of attempt span in ctx
+func injectTraceContext(ctx context.Context, header http.Header) {
+	span, ok := ctx.Value(spanKey{}).(Span)
+	if !ok {
+		return
+	}
+	sc := span.SpanContext()
+	if !sc.IsValid() {
+		return
+	}
+	header.Set(HeaderTraceParent, sc.TraceParent())
+	if sc.TraceState != "" {
+		header.Set(HeaderTraceState, sc.TraceState)
+	} else {
+		header.Del(HeaderTraceState)
+	}
+}
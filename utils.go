@@ -23,10 +23,9 @@ func GenRandIntn(n ...int) int {
 func AddSchemeSlice(sli []string) []string {
 	result := make([]string, len(sli))
 	for i, s := range sli {
-		if strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") {
-			continue
+		if !strings.HasPrefix(s, "http://") && !strings.HasPrefix(s, "https://") {
+			s = "http://" + s
 		}
-		s = "http://" + s
 		result[i] = s
 	}
 	return result